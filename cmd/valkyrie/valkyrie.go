@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/marianop9/valkyrie-migrate/pkg/cmd"
 )
@@ -11,5 +12,6 @@ func main() {
 
 	if err != nil {
 		fmt.Printf("command failed:\n %v\n", err)
+		os.Exit(1)
 	}
 }
\ No newline at end of file
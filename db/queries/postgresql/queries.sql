@@ -26,4 +26,65 @@ INSERT INTO migration (
     migration_group_id,
     name,
     executed_at
-) VALUES ($1, $2, $3);
\ No newline at end of file
+) VALUES ($1, $2, $3);
+
+-- name: GetMigrationGroupByName :one
+SELECT id, name
+FROM migration_group
+WHERE name = sqlc.arg(name)
+LIMIT 1;
+
+-- name: DeleteMigration :execrows
+DELETE FROM migration
+WHERE migration_group_id = $1
+    AND name = $2;
+
+-- name: LogMigrationMark :exec
+INSERT INTO migration_mark (
+    group_name,
+    name,
+    status,
+    reason,
+    marked_at
+) VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetRepeatableChecksums :many
+SELECT r.group_name,
+    r.name,
+    r.checksum
+FROM repeatable_migration r
+WHERE r.id = (
+    SELECT max(id)
+    FROM repeatable_migration
+    WHERE group_name = r.group_name
+        AND name = r.name
+);
+
+-- name: LogRepeatableMigration :exec
+INSERT INTO repeatable_migration (
+    group_name,
+    name,
+    checksum,
+    executed_at
+) VALUES ($1, $2, $3, $4);
+
+-- name: GetSeedChecksums :many
+SELECT profile,
+    name,
+    checksum
+FROM seed_run;
+
+-- name: DeleteSeedsByProfile :exec
+DELETE FROM seed_run
+WHERE profile = $1;
+
+-- name: LogSeed :exec
+INSERT INTO seed_run (
+    profile,
+    name,
+    checksum,
+    executed_at
+) VALUES ($1, $2, $3, $4)
+ON CONFLICT (profile, name) DO UPDATE
+SET checksum = excluded.checksum,
+    executed_at = excluded.executed_at;
@@ -4,7 +4,7 @@ SELECT mg.id,
     count(m.migration_group_id) AS migrationCount
 FROM migration_group mg	
     LEFT JOIN migration m on mg.id = m.migration_group_id
-GROUP BY m.migration_group_id;
+GROUP BY mg.id, mg.name;
 
 -- name: GetMigrationsByGroup :many
 SELECT m.name,
@@ -28,4 +28,71 @@ INSERT INTO migration (
     executed_at
 ) VALUES (
     :groupId, :name, :executedAt
-);
\ No newline at end of file
+);
+
+-- name: GetMigrationGroupByName :one
+SELECT id, name
+FROM migration_group
+WHERE name = :name
+LIMIT 1;
+
+-- name: DeleteMigration :execrows
+DELETE FROM migration
+WHERE migration_group_id = :groupId
+    AND name = :name;
+
+-- name: LogMigrationMark :exec
+INSERT INTO migration_mark (
+    group_name,
+    name,
+    status,
+    reason,
+    marked_at
+) VALUES (
+    :groupName, :name, :status, :reason, :markedAt
+);
+
+-- name: GetRepeatableChecksums :many
+SELECT r.group_name,
+    r.name,
+    r.checksum
+FROM repeatable_migration r
+WHERE r.id = (
+    SELECT max(id)
+    FROM repeatable_migration
+    WHERE group_name = r.group_name
+        AND name = r.name
+);
+
+-- name: LogRepeatableMigration :exec
+INSERT INTO repeatable_migration (
+    group_name,
+    name,
+    checksum,
+    executed_at
+) VALUES (
+    :groupName, :name, :checksum, :executedAt
+);
+
+-- name: GetSeedChecksums :many
+SELECT profile,
+    name,
+    checksum
+FROM seed_run;
+
+-- name: DeleteSeedsByProfile :exec
+DELETE FROM seed_run
+WHERE profile = :profile;
+
+-- name: LogSeed :exec
+INSERT INTO seed_run (
+    profile,
+    name,
+    checksum,
+    executed_at
+) VALUES (
+    :profile, :name, :checksum, :executedAt
+)
+ON CONFLICT (profile, name) DO UPDATE
+SET checksum = excluded.checksum,
+    executed_at = excluded.executed_at;
@@ -0,0 +1,7 @@
+CREATE TABLE repeatable_migration (
+    id SERIAL PRIMARY KEY,
+    group_name VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    executed_at TIMESTAMP NOT NULL
+);
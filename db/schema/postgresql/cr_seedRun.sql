@@ -0,0 +1,8 @@
+CREATE TABLE seed_run (
+    id SERIAL PRIMARY KEY,
+    profile VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    executed_at TIMESTAMP NOT NULL,
+    UNIQUE (profile, name)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE migration_mark (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_name VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL,
+    marked_at TIMESTAMP NOT NULL
+);
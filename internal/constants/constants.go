@@ -1,4 +1,13 @@
 package constants
 
 const DefaultDb = "valkyrie.db"
-const ConnFlagName = "conn"
\ No newline at end of file
+const ConnFlagName = "conn"
+const OutOfOrderFlagName = "out-of-order"
+const GroupFlagName = "group"
+const ExcludeGroupFlagName = "exclude-group"
+const WaitFlagName = "wait"
+const DriverFlagName = "driver"
+const ForeignKeysFlagName = "foreign-keys"
+const JournalModeFlagName = "journal-mode"
+const SynchronousFlagName = "synchronous"
+const BusyTimeoutFlagName = "busy-timeout"
@@ -3,22 +3,115 @@ package helpers
 import (
 	"encoding/json"
 	"os"
+
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
 )
 
 type ConnFile struct {
 	ConnectionString string
+	// Hooks are shell commands run around migrations.
+	Hooks models.HookCommands
+	// LintRules sets the severity of lint rules by name.
+	LintRules map[string]string
+	Timeouts  TimeoutConfig
+	Sqlite    SqliteConfig
+}
+
+// TimeoutConfig holds the defaults of the timeout flags, as durations like "30s".
+type TimeoutConfig struct {
+	Statement string
+	Lock      string
+	Run       string
+}
+
+// SqliteConfig holds the defaults of the SQLite pragma flags, with the busy
+// timeout as a duration like "5s".
+type SqliteConfig struct {
+	ForeignKeys string
+	JournalMode string
+	Synchronous string
+	BusyTimeout string
 }
 
 func GetConnString(connFilePath string) (string, error) {
+	connFile, err := readConnFile(connFilePath)
+
+	return connFile.ConnectionString, err
+}
+
+// GetHookCommands reads the hook commands of the config file, a missing
+// path has none.
+func GetHookCommands(connFilePath string) (models.HookCommands, error) {
+	if connFilePath == "" {
+		return models.HookCommands{}, nil
+	}
+
+	connFile, err := readConnFile(connFilePath)
+
+	return connFile.Hooks, err
+}
+
+// GetLintRules reads the lint rule severities of the config file, a missing
+// path has none.
+func GetLintRules(connFilePath string) (map[string]string, error) {
+	if connFilePath == "" {
+		return map[string]string{}, nil
+	}
+
+	connFile, err := readConnFile(connFilePath)
+
+	return connFile.LintRules, err
+}
+
+// GetTimeoutConfig reads the timeout defaults of the config file, a missing
+// path has none.
+func GetTimeoutConfig(connFilePath string) (TimeoutConfig, error) {
+	if connFilePath == "" {
+		return TimeoutConfig{}, nil
+	}
+
+	connFile, err := readConnFile(connFilePath)
+
+	return connFile.Timeouts, err
+}
+
+// GetSqliteConfig reads the SQLite pragma defaults of the config file, a
+// missing path has none.
+func GetSqliteConfig(connFilePath string) (SqliteConfig, error) {
+	if connFilePath == "" {
+		return SqliteConfig{}, nil
+	}
+
+	connFile, err := readConnFile(connFilePath)
+
+	return connFile.Sqlite, err
+}
+
+func readConnFile(connFilePath string) (ConnFile, error) {
 	buf, err := os.ReadFile(connFilePath)
 
 	connFile := ConnFile{}
 
 	if err != nil {
-		return "", err
+		return connFile, err
 	}
 
 	err = json.Unmarshal(buf, &connFile)
 
-	return connFile.ConnectionString, err
-}
\ No newline at end of file
+	return connFile, err
+}
+
+// ResolveConnString picks the connection string for a command: the --conn flag
+// wins, then the connFile argument, falling back to the default database.
+func ResolveConnString(connFlag string, connFilePath string) (string, error) {
+	if connFlag != "" {
+		return connFlag, nil
+	}
+
+	if connFilePath != "" {
+		return GetConnString(connFilePath)
+	}
+
+	return constants.DefaultDb, nil
+}
@@ -1,19 +1,58 @@
 package helpers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// pingTimeout bounds how long opening a database waits for it to answer.
+const pingTimeout = 10 * time.Second
+
 func GetDb(dsn string) (*sql.DB, error) {
 
 	if db, err := sql.Open("sqlite3", dsn); err != nil {
-		return nil, fmt.Errorf("failed to connect to db: %v\n %v", dsn, err.Error())
+		return nil, fmt.Errorf("failed to connect to db: %v\n %v", RedactDsn(dsn), err.Error())
+	} else if err := ping(db, dsn); err != nil {
+		return nil, err
 	} else {
 		return db, nil
 	}
 
 }
 
+// ping connects to the database, as sql.Open doesn't, closing it when it
+// can't be reached.
+func ping(db *sql.DB, dsn string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to db: %v\n %v", RedactDsn(dsn), err.Error())
+	}
+
+	return nil
+}
+
+var passwordRe = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// RedactDsn hides the password of a connection string, in a url or key=value
+// form, so it can be printed.
+func RedactDsn(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && strings.Contains(dsn, "://") {
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+
+	return passwordRe.ReplaceAllString(dsn, "${1}xxxxx")
+}
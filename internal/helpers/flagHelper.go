@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// AddGroupFlags registers the --group and --exclude-group filters on a command.
+func AddGroupFlags(c *cobra.Command) {
+	c.Flags().StringSlice(constants.GroupFlagName, nil, "only includes groups matching the glob pattern (repeatable)")
+	c.Flags().StringSlice(constants.ExcludeGroupFlagName, nil, "excludes groups matching the glob pattern (repeatable)")
+}
+
+// GetGroupFlags returns the include and exclude patterns registered by AddGroupFlags.
+func GetGroupFlags(c *cobra.Command) (include []string, exclude []string, err error) {
+	if include, err = c.Flags().GetStringSlice(constants.GroupFlagName); err != nil {
+		return nil, nil, err
+	}
+
+	if exclude, err = c.Flags().GetStringSlice(constants.ExcludeGroupFlagName); err != nil {
+		return nil, nil, err
+	}
+
+	return include, exclude, nil
+}
+
+// AddWaitFlag registers the --wait flag, how long a command retries reaching
+// the database before failing.
+func AddWaitFlag(c *cobra.Command) {
+	c.Flags().Duration(constants.WaitFlagName, 0, "retries connecting to the database until it's reachable, up to the given duration like 60s")
+}
+
+// AddSqliteFlags registers the flags setting the pragmas of SQLite connections.
+func AddSqliteFlags(c *cobra.Command) {
+	c.Flags().String(constants.ForeignKeysFlagName, "", "enforces foreign keys on SQLite connections: on or off")
+	c.Flags().String(constants.JournalModeFlagName, "", "journal mode of SQLite databases: delete, truncate, persist, memory, wal or off")
+	c.Flags().String(constants.SynchronousFlagName, "", "synchronous mode of SQLite connections: off, normal, full or extra")
+	c.Flags().Duration(constants.BusyTimeoutFlagName, 0, "how long SQLite statements wait for a locked database, like 5s")
+}
+
+// GetSqlitePragmas reads the flags registered by AddSqliteFlags, falling back
+// to the Sqlite section of the config file.
+func GetSqlitePragmas(c *cobra.Command, connFilePath string) (models.SqlitePragmas, error) {
+	config, err := GetSqliteConfig(connFilePath)
+	if err != nil {
+		return models.SqlitePragmas{}, err
+	}
+
+	pragmas := models.SqlitePragmas{
+		ForeignKeys: config.ForeignKeys,
+		JournalMode: config.JournalMode,
+		Synchronous: config.Synchronous,
+	}
+
+	if config.BusyTimeout != "" {
+		if pragmas.BusyTimeout, err = time.ParseDuration(config.BusyTimeout); err != nil {
+			return models.SqlitePragmas{}, fmt.Errorf("invalid busy timeout '%s' in config file: %v", config.BusyTimeout, err)
+		}
+	}
+
+	flags := map[string]*string{
+		constants.ForeignKeysFlagName: &pragmas.ForeignKeys,
+		constants.JournalModeFlagName: &pragmas.JournalMode,
+		constants.SynchronousFlagName: &pragmas.Synchronous,
+	}
+	for name, value := range flags {
+		if c.Flags().Changed(name) {
+			if *value, err = c.Flags().GetString(name); err != nil {
+				return models.SqlitePragmas{}, err
+			}
+		}
+	}
+
+	if c.Flags().Changed(constants.BusyTimeoutFlagName) {
+		if pragmas.BusyTimeout, err = c.Flags().GetDuration(constants.BusyTimeoutFlagName); err != nil {
+			return models.SqlitePragmas{}, err
+		}
+	}
+
+	return pragmas, nil
+}
@@ -10,7 +10,9 @@ import (
 func GetPostgresDb(dbUrl string) (*sql.DB, error) {
 
 	if db, err := sql.Open("pgx", dbUrl); err != nil {
-		return nil, fmt.Errorf("failed to connect to db: %v\n %v", dbUrl, err.Error())
+		return nil, fmt.Errorf("failed to connect to db: %v\n %v", RedactDsn(dbUrl), err.Error())
+	} else if err := ping(db, dbUrl); err != nil {
+		return nil, err
 	} else {
 		return db, nil
 	}
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ReadMapping loads a mapping report written by the import command.
+func ReadMapping(mappingPath string) (*Mapping, error) {
+	buf, err := os.ReadFile(mappingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := &Mapping{}
+	if err := json.Unmarshal(buf, mapping); err != nil {
+		return nil, fmt.Errorf("failed to read mapping file '%s': %v", mappingPath, err)
+	}
+
+	return mapping, nil
+}
+
+// ReadAppliedVersions returns the versions the source tool logged as applied
+// in its tracking table. golang-migrate only stores the current version, so
+// every mapped version up to it is considered applied.
+func ReadAppliedVersions(db *sql.DB, from string, mapping *Mapping) ([]string, error) {
+	switch from {
+	case FromGolangMigrate:
+		return readGolangMigrateVersions(db, mapping)
+	case FromGoose:
+		return readGooseVersions(db)
+	case FromFlyway:
+		return readFlywayVersions(db)
+	}
+
+	return nil, fmt.Errorf("unsupported source tool '%s', expected one of: %s, %s, %s", from, FromGolangMigrate, FromGoose, FromFlyway)
+}
+
+func readGolangMigrateVersions(db *sql.DB, mapping *Mapping) ([]string, error) {
+	var version int64
+	var dirty bool
+
+	err := db.QueryRow(`SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+
+	if dirty {
+		return nil, fmt.Errorf("schema_migrations is dirty at version %v, fix it with golang-migrate before importing", version)
+	}
+
+	current := fmt.Sprint(version)
+	versions := make([]string, 0)
+	for _, mig := range mapping.Migrations {
+		if compareVersions(mig.Version, current) <= 0 {
+			versions = append(versions, mig.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+func readGooseVersions(db *sql.DB) ([]string, error) {
+	// goose logs a row per up or down, the latest row of a version wins
+	rows, err := db.Query(`SELECT version_id, is_applied FROM goose_db_version ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read goose_db_version: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	order := make([]string, 0)
+
+	for rows.Next() {
+		var versionId int64
+		var isApplied bool
+		if err := rows.Scan(&versionId, &isApplied); err != nil {
+			return nil, err
+		}
+
+		// version 0 is the row goose inserts when creating its table
+		if versionId == 0 {
+			continue
+		}
+
+		version := fmt.Sprint(versionId)
+		if _, ok := applied[version]; !ok {
+			order = append(order, version)
+		}
+		applied[version] = isApplied
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(order))
+	for _, version := range order {
+		if applied[version] {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+func readFlywayVersions(db *sql.DB) ([]string, error) {
+	// repeatable migrations are logged without a version
+	rows, err := db.Query(`SELECT version
+		FROM flyway_schema_history
+		WHERE version IS NOT NULL
+			AND success = true
+		ORDER BY installed_rank`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flyway_schema_history: %v", err)
+	}
+	defer rows.Close()
+
+	versions := make([]string, 0)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
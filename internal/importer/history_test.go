@@ -0,0 +1,162 @@
+package importer_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/importer"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func getHistoryDb(t *testing.T, ddl string) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: opens a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(ddl); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func testMapping(versions ...string) *importer.Mapping {
+	mapping := &importer.Mapping{From: importer.FromGoose}
+	for _, version := range versions {
+		mapping.Migrations = append(mapping.Migrations, importer.MappedMigration{
+			Version: version,
+			Group:   "Imported",
+			Name:    "20240101_" + version + ".sql",
+		})
+	}
+
+	return mapping
+}
+
+func TestReadAppliedVersions(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		from        string
+		ddl         string
+		mapping     *importer.Mapping
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			desc: "goose keeps the latest row of every version",
+			from: importer.FromGoose,
+			ddl: `CREATE TABLE goose_db_version (id INTEGER PRIMARY KEY, version_id INTEGER, is_applied BOOLEAN);
+				INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1), (1, 1), (2, 1), (3, 1), (2, 0);`,
+			expected: []string{"1", "3"},
+		},
+		{
+			desc:     "goose without applied versions",
+			from:     importer.FromGoose,
+			ddl:      `CREATE TABLE goose_db_version (id INTEGER PRIMARY KEY, version_id INTEGER, is_applied BOOLEAN);`,
+			expected: []string{},
+		},
+		{
+			desc: "flyway skips repeatable and failed migrations",
+			from: importer.FromFlyway,
+			ddl: `CREATE TABLE flyway_schema_history (installed_rank INTEGER, version TEXT, success BOOLEAN);
+				INSERT INTO flyway_schema_history VALUES (2, '1.2', 1), (1, '1', 1), (3, NULL, 1), (4, '1.10', 0);`,
+			expected: []string{"1", "1.2"},
+		},
+		{
+			desc: "golang-migrate applies every mapped version up to the current one",
+			from: importer.FromGolangMigrate,
+			ddl: `CREATE TABLE schema_migrations (version INTEGER, dirty BOOLEAN);
+				INSERT INTO schema_migrations VALUES (2, 0);`,
+			mapping:  testMapping("0001", "0002", "0010"),
+			expected: []string{"0001", "0002"},
+		},
+		{
+			desc:     "golang-migrate without a version",
+			from:     importer.FromGolangMigrate,
+			ddl:      `CREATE TABLE schema_migrations (version INTEGER, dirty BOOLEAN);`,
+			mapping:  testMapping("0001"),
+			expected: []string{},
+		},
+		{
+			desc: "golang-migrate dirty version",
+			from: importer.FromGolangMigrate,
+			ddl: `CREATE TABLE schema_migrations (version INTEGER, dirty BOOLEAN);
+				INSERT INTO schema_migrations VALUES (2, 1);`,
+			mapping:     testMapping("0001", "0002"),
+			expectedErr: true,
+		},
+		{
+			desc:        "missing tracking table",
+			from:        importer.FromFlyway,
+			ddl:         `CREATE TABLE users (id INTEGER);`,
+			expectedErr: true,
+		},
+		{
+			desc:        "unsupported tool",
+			from:        "liquibase",
+			ddl:         `CREATE TABLE users (id INTEGER);`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mapping := tC.mapping
+			if mapping == nil {
+				mapping = testMapping()
+			}
+
+			versions, err := importer.ReadAppliedVersions(getHistoryDb(t, tC.ddl), tC.from, mapping)
+			if tC.expectedErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if strings.Join(versions, ",") != strings.Join(tC.expected, ",") {
+				t.Errorf("expected '%v', got '%v'", tC.expected, versions)
+			}
+		})
+	}
+}
+
+func TestFindByVersion(t *testing.T) {
+	mapping := testMapping("0001", "1.2", "20230405101112")
+
+	testCases := []struct {
+		desc     string
+		version  string
+		expected string
+	}{
+		{desc: "same version", version: "0001", expected: "20240101_0001.sql"},
+		{desc: "leading zeros are ignored", version: "1", expected: "20240101_0001.sql"},
+		{desc: "trailing zero parts are ignored", version: "1.0", expected: "20240101_0001.sql"},
+		{desc: "dotted version", version: "1.02", expected: "20240101_1.2.sql"},
+		{desc: "timestamp version", version: "20230405101112", expected: "20240101_20230405101112.sql"},
+		{desc: "unmapped version", version: "2"},
+		{desc: "unmapped dotted version", version: "1.2.1"},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mig := mapping.FindByVersion(tC.version)
+
+			name := ""
+			if mig != nil {
+				name = mig.Name
+			}
+
+			if name != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, name)
+			}
+		})
+	}
+}
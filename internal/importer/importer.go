@@ -0,0 +1,334 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+)
+
+const (
+	FromGolangMigrate = "golang-migrate"
+	FromGoose         = "goose"
+	FromFlyway        = "flyway"
+)
+
+var (
+	golangMigrateRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	gooseRe         = regexp.MustCompile(`^(\d+)_(.+)\.(sql|go)$`)
+	flywayRe        = regexp.MustCompile(`^([VUR])([\d._]*)__(.+)\.sql$`)
+	descriptionRe   = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// MappedMigration relates a file of the source tool to the valkyrie migration
+// it was converted to.
+type MappedMigration struct {
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Group   string `json:"group"`
+	Name    string `json:"name"`
+	Content []byte `json:"-"`
+}
+
+// SkippedFile is a source file that has no valkyrie equivalent.
+type SkippedFile struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// ImportWarning is a converted file whose annotations valkyrie dropped.
+type ImportWarning struct {
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// Mapping is the result of converting a migration folder of another tool.
+type Mapping struct {
+	From       string            `json:"from"`
+	Migrations []MappedMigration `json:"migrations"`
+	Skipped    []SkippedFile     `json:"skipped"`
+	Warnings   []ImportWarning   `json:"warnings"`
+}
+
+// FindByVersion returns the migration converted from the given source version,
+// or nil. Leading zeros are ignored, so '0001' matches '1'.
+func (m *Mapping) FindByVersion(version string) *MappedMigration {
+	for i, mig := range m.Migrations {
+		if compareVersions(mig.Version, version) == 0 {
+			return &m.Migrations[i]
+		}
+	}
+
+	return nil
+}
+
+type sourceMigration struct {
+	version     string
+	description string
+	source      string
+	content     []byte
+}
+
+// Convert reads the migrations of srcDir, laid out for the given tool, and
+// maps them to '<yyyymmdd>_<description>.sql' files of a single group.
+// Versions that start with a yyyymmdd date keep it, sequential versions are
+// dated baseDate and prefixed with their position so they keep their order.
+func Convert(from string, srcDir string, group string, baseDate string) (*Mapping, error) {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := &Mapping{
+		From:       from,
+		Migrations: []MappedMigration{},
+		Skipped:    []SkippedFile{},
+		Warnings:   []ImportWarning{},
+	}
+
+	sources := make([]sourceMigration, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fileName := entry.Name()
+
+		var mig *sourceMigration
+		var reason string
+
+		switch from {
+		case FromGolangMigrate:
+			mig, reason = parseGolangMigrate(fileName)
+		case FromGoose:
+			mig, reason = parseGoose(fileName)
+		case FromFlyway:
+			mig, reason = parseFlyway(fileName)
+		default:
+			return nil, fmt.Errorf("unsupported source tool '%s', expected one of: %s, %s, %s", from, FromGolangMigrate, FromGoose, FromFlyway)
+		}
+
+		if mig == nil {
+			mapping.Skipped = append(mapping.Skipped, SkippedFile{Source: fileName, Reason: reason})
+			continue
+		}
+
+		content, err := os.ReadFile(path.Join(srcDir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		if from == FromGoose {
+			goose, err := ParseGoose(content)
+			if err != nil {
+				return nil, fmt.Errorf("(%s): %v", fileName, err)
+			}
+
+			if goose.NoTransaction {
+				mapping.Skipped = append(mapping.Skipped, SkippedFile{Source: fileName, Reason: "NO TRANSACTION migrations can't run inside valkyrie's transaction, apply it by hand"})
+				continue
+			} else if goose.EnvSub {
+				mapping.Skipped = append(mapping.Skipped, SkippedFile{Source: fileName, Reason: "ENVSUB migrations depend on environment variables valkyrie doesn't substitute"})
+				continue
+			} else if goose.StatementBlocks {
+				mapping.Warnings = append(mapping.Warnings, ImportWarning{Source: fileName, Message: "StatementBegin/End annotations were dropped, the file runs as a single script"})
+			}
+
+			content = goose.Up
+		}
+
+		mig.content = content
+		sources = append(sources, *mig)
+	}
+
+	mapping.Migrations = assignNames(sources, group, baseDate)
+
+	return mapping, nil
+}
+
+func parseGolangMigrate(fileName string) (*sourceMigration, string) {
+	match := golangMigrateRe.FindStringSubmatch(fileName)
+	if match == nil {
+		return nil, "file name doesn't match '<version>_<title>.(up|down).sql'"
+	} else if match[3] == "down" {
+		return nil, "down migrations are not supported"
+	}
+
+	return &sourceMigration{version: match[1], description: match[2], source: fileName}, ""
+}
+
+func parseGoose(fileName string) (*sourceMigration, string) {
+	match := gooseRe.FindStringSubmatch(fileName)
+	if match == nil {
+		return nil, "file name doesn't match '<version>_<name>.sql'"
+	} else if match[3] == "go" {
+		return nil, "go migrations are not supported"
+	}
+
+	return &sourceMigration{version: match[1], description: match[2], source: fileName}, ""
+}
+
+func parseFlyway(fileName string) (*sourceMigration, string) {
+	match := flywayRe.FindStringSubmatch(fileName)
+	if match == nil {
+		return nil, "file name doesn't match 'V<version>__<description>.sql'"
+	}
+
+	switch match[1] {
+	case "U":
+		return nil, "undo migrations are not supported"
+	case "R":
+		return nil, "repeatable migrations are not supported"
+	}
+
+	if match[2] == "" {
+		return nil, "versioned migration has no version"
+	}
+
+	// flyway accepts both '.' and '_' as version separators
+	version := strings.ReplaceAll(match[2], "_", ".")
+
+	return &sourceMigration{version: version, description: match[3], source: fileName}, ""
+}
+
+// GooseMigration is a parsed goose sql migration.
+type GooseMigration struct {
+	// Up are the statements of the '-- +goose Up' section.
+	Up []byte
+	// NoTransaction is set by '-- +goose NO TRANSACTION', the migration
+	// can't run inside valkyrie's transaction.
+	NoTransaction bool
+	// EnvSub is set by '-- +goose ENVSUB ON', goose replaced environment
+	// variables in the statements.
+	EnvSub bool
+	// StatementBlocks is set by '-- +goose StatementBegin' blocks, which
+	// valkyrie doesn't need as it runs the file as a single script.
+	StatementBlocks bool
+}
+
+// ParseGoose reads the '-- +goose Up' section and the annotations of a goose
+// migration.
+func ParseGoose(content []byte) (*GooseMigration, error) {
+	lines := strings.Split(string(content), "\n")
+	upLines := make([]string, 0, len(lines))
+	inUp, foundUp := false, false
+	mig := &GooseMigration{}
+
+	for _, line := range lines {
+		annotation := strings.TrimSpace(line)
+
+		if strings.HasPrefix(annotation, "-- +goose") {
+			switch strings.ToUpper(strings.Join(strings.Fields(strings.TrimPrefix(annotation, "-- +goose")), " ")) {
+			case "UP":
+				inUp, foundUp = true, true
+			case "DOWN":
+				inUp = false
+			case "NO TRANSACTION":
+				mig.NoTransaction = true
+			case "ENVSUB ON":
+				mig.EnvSub = mig.EnvSub || inUp
+			case "ENVSUB OFF":
+			case "STATEMENTBEGIN", "STATEMENTEND":
+				mig.StatementBlocks = mig.StatementBlocks || inUp
+			default:
+				return nil, fmt.Errorf("unsupported annotation '%s'", annotation)
+			}
+			continue
+		}
+
+		if inUp {
+			upLines = append(upLines, line)
+		}
+	}
+
+	if !foundUp {
+		return nil, fmt.Errorf("missing '-- +goose Up' annotation")
+	}
+
+	mig.Up = []byte(strings.TrimSpace(strings.Join(upLines, "\n")) + "\n")
+	return mig, nil
+}
+
+func assignNames(sources []sourceMigration, group string, baseDate string) []MappedMigration {
+	sort.SliceStable(sources, func(i, j int) bool {
+		return compareVersions(sources[i].version, sources[j].version) < 0
+	})
+
+	timestamped := true
+	for _, mig := range sources {
+		if !isDatedVersion(mig.version) {
+			timestamped = false
+			break
+		}
+	}
+
+	width := len(fmt.Sprint(len(sources)))
+	if width < 4 {
+		width = 4
+	}
+
+	mapped := make([]MappedMigration, 0, len(sources))
+	for i, mig := range sources {
+		date, suffix := baseDate, fmt.Sprintf("%0*d", width, i+1)
+		if timestamped {
+			date, suffix = mig.version[:8], mig.version[8:]
+		}
+
+		name := date + "_"
+		if suffix != "" {
+			name += suffix + "_"
+		}
+		name += descriptionRe.ReplaceAllString(mig.description, "_") + ".sql"
+
+		mapped = append(mapped, MappedMigration{
+			Version: mig.version,
+			Source:  mig.source,
+			Group:   group,
+			Name:    name,
+			Content: mig.content,
+		})
+	}
+
+	return mapped
+}
+
+func isDatedVersion(version string) bool {
+	if len(version) < 8 || strings.Contains(version, ".") {
+		return false
+	}
+
+	return migrations.CheckDate(version[:8]) == nil
+}
+
+// compareVersions compares dotted numeric versions, ignoring leading zeros.
+func compareVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = strings.TrimLeft(aParts[i], "0")
+		}
+		if i < len(bParts) {
+			bPart = strings.TrimLeft(bParts[i], "0")
+		}
+
+		if len(aPart) != len(bPart) {
+			if len(aPart) < len(bPart) {
+				return -1
+			}
+			return 1
+		}
+
+		if c := strings.Compare(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
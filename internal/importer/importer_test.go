@@ -0,0 +1,168 @@
+package importer_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/importer"
+)
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		from            string
+		files           []string
+		expectedNames   []string
+		expectedSkipped int
+	}{
+		{
+			desc:            "golang-migrate sequential versions",
+			from:            importer.FromGolangMigrate,
+			files:           []string{"0010_b.up.sql", "0002_a.up.sql", "0002_a.down.sql"},
+			expectedNames:   []string{"20240101_0001_a.sql", "20240101_0002_b.sql"},
+			expectedSkipped: 1,
+		},
+		{
+			desc:          "golang-migrate timestamp versions",
+			from:          importer.FromGolangMigrate,
+			files:         []string{"20230405101112_add users.up.sql"},
+			expectedNames: []string{"20230405_101112_add_users.sql"},
+		},
+		{
+			desc:            "flyway dotted versions",
+			from:            importer.FromFlyway,
+			files:           []string{"V1_10__c.sql", "V1.2__b.sql", "V1__a.sql", "R__views.sql", "U1__a.sql"},
+			expectedNames:   []string{"20240101_0001_a.sql", "20240101_0002_b.sql", "20240101_0003_c.sql"},
+			expectedSkipped: 2,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srcDir := t.TempDir()
+			for _, file := range tC.files {
+				if err := os.WriteFile(path.Join(srcDir, file), []byte("SELECT 1;"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mapping, err := importer.Convert(tC.from, srcDir, "Imported", "20240101")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			names := make([]string, 0)
+			for _, mig := range mapping.Migrations {
+				names = append(names, mig.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(tC.expectedNames, ",") {
+				t.Errorf("expected %v, got %v", tC.expectedNames, names)
+			}
+
+			if count := len(mapping.Skipped); count != tC.expectedSkipped {
+				t.Errorf("expected %v skipped files, got %v", tC.expectedSkipped, count)
+			}
+		})
+	}
+}
+
+func TestParseGoose(t *testing.T) {
+	content := `-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users (id INT);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE users;
+`
+
+	mig, err := importer.ParseGoose([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := "CREATE TABLE users (id INT);\n"; string(mig.Up) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, mig.Up)
+	}
+
+	if _, err := importer.ParseGoose([]byte("CREATE TABLE users (id INT);")); err == nil {
+		t.Error("expected an error for a file without annotations")
+	}
+}
+
+func TestConvertGooseAnnotations(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		content          string
+		expectedImported int
+		expectedSkipped  int
+		expectedWarnings int
+		expectedErr      bool
+	}{
+		{
+			desc:             "plain migration",
+			content:          "-- +goose Up\nCREATE TABLE users (id INT);\n-- +goose Down\nDROP TABLE users;\n",
+			expectedImported: 1,
+		},
+		{
+			desc:            "no transaction",
+			content:         "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY users_id ON users (id);\n",
+			expectedSkipped: 1,
+		},
+		{
+			desc:            "envsub",
+			content:         "-- +goose Up\n-- +goose ENVSUB ON\nCREATE ROLE ${ROLE_NAME};\n-- +goose ENVSUB OFF\n",
+			expectedSkipped: 1,
+		},
+		{
+			desc:             "statement blocks",
+			content:          "-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE users (id INT);\n-- +goose StatementEnd\n",
+			expectedImported: 1,
+			expectedWarnings: 1,
+		},
+		{
+			desc:             "statement blocks in the down section",
+			content:          "-- +goose Up\nCREATE TABLE users (id INT);\n-- +goose Down\n-- +goose StatementBegin\nDROP TABLE users;\n-- +goose StatementEnd\n",
+			expectedImported: 1,
+		},
+		{
+			desc:        "unknown annotation",
+			content:     "-- +goose Up\n-- +goose Sideways\nCREATE TABLE users (id INT);\n",
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srcDir := t.TempDir()
+			if err := os.WriteFile(path.Join(srcDir, "00001_users.sql"), []byte(tC.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			mapping, err := importer.Convert(importer.FromGoose, srcDir, "Imported", "20240101")
+			if tC.expectedErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if count := len(mapping.Migrations); count != tC.expectedImported {
+				t.Errorf("expected %v imported files, got %v", tC.expectedImported, count)
+			}
+
+			if count := len(mapping.Skipped); count != tC.expectedSkipped {
+				t.Errorf("expected %v skipped files, got %v", tC.expectedSkipped, count)
+			}
+
+			if count := len(mapping.Warnings); count != tC.expectedWarnings {
+				t.Errorf("expected %v warnings, got %v", tC.expectedWarnings, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+// Package lint flags risky statements in migration files before they reach
+// a database.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityOff Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = []string{"off", "info", "warning", "error"}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func ParseSeverity(severity string) (Severity, error) {
+	for i, name := range severityNames {
+		if name == severity {
+			return Severity(i), nil
+		}
+	}
+
+	return SeverityOff, fmt.Errorf("invalid severity '%s', expected one of: %s", severity, strings.Join(severityNames, ", "))
+}
+
+const (
+	DialectPostgres = "postgres"
+	DialectSqlite   = "sqlite"
+)
+
+// Rule is a risky pattern checked on every statement of a file.
+type Rule struct {
+	Name     string
+	Severity Severity
+	// Dialect limits the rule to one dialect, it applies to both when empty.
+	Dialect string
+	Message string
+	check   func(stmt *statement, file *fileState) bool
+}
+
+// fileState is what earlier statements of a file tell about later ones.
+type fileState struct {
+	// createdTables are created in the file, so they're still empty.
+	createdTables map[string]bool
+}
+
+// alterCreatedTable reports whether the statement alters a table created
+// earlier in the file.
+func (file *fileState) alterCreatedTable(stmt *statement) bool {
+	match := alterClausesRe.FindStringSubmatch(stmt.Text)
+	return match != nil && file.createdTables[normalizeName(match[1])]
+}
+
+var (
+	dropTableRe     = regexp.MustCompile(`(?i)^DROP TABLE\b`)
+	alterTableRe    = regexp.MustCompile(`(?i)^ALTER TABLE\b`)
+	dropClauseRe    = regexp.MustCompile(`(?i)\bDROP (\w+)`)
+	createTableRe   = regexp.MustCompile(`(?i)^CREATE (?:TEMP |TEMPORARY |UNLOGGED )?TABLE (?:IF NOT EXISTS )?([^\s(]+)`)
+	createIndexRe   = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?INDEX\b`)
+	indexTableRe    = regexp.MustCompile(`(?i)\bON (?:ONLY )?([^\s(]+)`)
+	concurrentlyRe  = regexp.MustCompile(`(?i)\bCONCURRENTLY\b`)
+	alterTypeRe     = regexp.MustCompile(`(?i)\bALTER (?:COLUMN )?\S+ (?:SET DATA )?TYPE\b`)
+	sqliteAlterRe   = regexp.MustCompile(`(?i)\b(ALTER COLUMN|ADD CONSTRAINT|DROP CONSTRAINT)\b`)
+	updateDeleteRe  = regexp.MustCompile(`(?i)^(UPDATE|DELETE FROM)\b`)
+	whereRe         = regexp.MustCompile(`(?i)\bWHERE\b`)
+	createObjectRe  = regexp.MustCompile(`(?i)^CREATE (?:TEMP |TEMPORARY |UNLOGGED |UNIQUE )?(TABLE|INDEX|SCHEMA|SEQUENCE)\b`)
+	ifNotExistsRe   = regexp.MustCompile(`(?i)\bIF NOT EXISTS\b`)
+	addClauseRe     = regexp.MustCompile(`(?i)^ADD (?:COLUMN )?(\w+)`)
+	notNullRe       = regexp.MustCompile(`(?i)\bNOT NULL\b`)
+	defaultRe       = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+	generatedRe     = regexp.MustCompile(`(?i)\bGENERATED\b`)
+	alterClausesRe  = regexp.MustCompile(`(?i)^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?(\S+) `)
+	constraintAddRe = regexp.MustCompile(`(?i)^(CONSTRAINT|PRIMARY|UNIQUE|FOREIGN|CHECK|EXCLUDE)$`)
+)
+
+// dropClauseKeywords follow DROP in ALTER TABLE clauses that don't drop a column.
+var dropClauseKeywords = map[string]bool{
+	"CONSTRAINT": true,
+	"DEFAULT":    true,
+	"NOT":        true,
+	"IDENTITY":   true,
+	"EXPRESSION": true,
+}
+
+var Rules = []Rule{
+	{
+		Name:     "drop-table",
+		Severity: SeverityError,
+		Message:  "drops a table and its data",
+		check: func(stmt *statement, file *fileState) bool {
+			return dropTableRe.MatchString(stmt.Text)
+		},
+	},
+	{
+		Name:     "drop-column",
+		Severity: SeverityError,
+		Message:  "drops a column and its data, deploy code that stops using it first",
+		check: func(stmt *statement, file *fileState) bool {
+			if !alterTableRe.MatchString(stmt.Text) {
+				return false
+			}
+
+			for _, match := range dropClauseRe.FindAllStringSubmatch(stmt.Text, -1) {
+				if !dropClauseKeywords[strings.ToUpper(match[1])] {
+					return true
+				}
+			}
+
+			return false
+		},
+	},
+	{
+		Name:     "add-column-not-null",
+		Severity: SeverityError,
+		Message:  "adds a NOT NULL column without a default, which fails on tables with rows",
+		check: func(stmt *statement, file *fileState) bool {
+			if file.alterCreatedTable(stmt) {
+				return false
+			}
+
+			for _, clause := range alterClauses(stmt.Text) {
+				match := addClauseRe.FindStringSubmatch(clause)
+				if match == nil || constraintAddRe.MatchString(match[1]) {
+					continue
+				}
+
+				if notNullRe.MatchString(clause) && !defaultRe.MatchString(clause) && !generatedRe.MatchString(clause) {
+					return true
+				}
+			}
+
+			return false
+		},
+	},
+	{
+		Name:     "create-index-concurrently",
+		Severity: SeverityWarning,
+		Dialect:  DialectPostgres,
+		Message:  "blocks writes to the table while the index builds, build indexes on large tables CONCURRENTLY outside the migration",
+		check: func(stmt *statement, file *fileState) bool {
+			if !createIndexRe.MatchString(stmt.Text) || concurrentlyRe.MatchString(stmt.Text) {
+				return false
+			}
+
+			// indexes on tables created in the same file are built on empty tables
+			match := indexTableRe.FindStringSubmatch(stmt.Text)
+			return match == nil || !file.createdTables[normalizeName(match[1])]
+		},
+	},
+	{
+		Name:     "table-rewrite",
+		Severity: SeverityWarning,
+		Dialect:  DialectPostgres,
+		Message:  "changes a column type, which may rewrite the table while locking it",
+		check: func(stmt *statement, file *fileState) bool {
+			return alterTypeRe.MatchString(stmt.Text) && alterTableRe.MatchString(stmt.Text) && !file.alterCreatedTable(stmt)
+		},
+	},
+	{
+		Name:     "unsupported-alter",
+		Severity: SeverityError,
+		Dialect:  DialectSqlite,
+		Message:  "SQLite can't alter columns or constraints, the table has to be rebuilt",
+		check: func(stmt *statement, file *fileState) bool {
+			return alterTableRe.MatchString(stmt.Text) && sqliteAlterRe.MatchString(stmt.Text)
+		},
+	},
+	{
+		Name:     "missing-where",
+		Severity: SeverityWarning,
+		Message:  "updates or deletes every row of the table",
+		check: func(stmt *statement, file *fileState) bool {
+			return updateDeleteRe.MatchString(stmt.Text) && !whereRe.MatchString(stmt.Text)
+		},
+	},
+	{
+		Name:     "missing-if-not-exists",
+		Severity: SeverityInfo,
+		Message:  "fails if the object already exists, add IF NOT EXISTS",
+		check: func(stmt *statement, file *fileState) bool {
+			return createObjectRe.MatchString(stmt.Text) && !ifNotExistsRe.MatchString(stmt.Text)
+		},
+	},
+}
+
+func findRule(name string) *Rule {
+	for i := range Rules {
+		if Rules[i].Name == name {
+			return &Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// Config selects the rules of a lint run.
+type Config struct {
+	// Dialect skips the rules of other dialects, every rule runs when empty.
+	Dialect string
+	// Severities override the default severity of rules by name, SeverityOff
+	// disables a rule.
+	Severities map[string]Severity
+}
+
+// Validate checks the dialect and the rule names of the config.
+func (config Config) Validate() error {
+	if config.Dialect != "" && config.Dialect != DialectPostgres && config.Dialect != DialectSqlite {
+		return fmt.Errorf("unsupported dialect '%s', expected one of: %s, %s", config.Dialect, DialectPostgres, DialectSqlite)
+	}
+
+	for name := range config.Severities {
+		if findRule(name) == nil {
+			return fmt.Errorf("unknown lint rule '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+func (config Config) severity(rule *Rule) Severity {
+	if severity, ok := config.Severities[rule.Name]; ok {
+		return severity
+	}
+
+	return rule.Severity
+}
+
+// Finding is a statement matching a rule.
+type Finding struct {
+	File     string
+	Line     int
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s [%s] %s", f.File, f.Line, f.Severity, f.Rule, f.Message)
+}
+
+// Lint checks every statement of a sql file against the rules of the config.
+func Lint(fileName string, sql string, config Config) []Finding {
+	statements, fileSuppressions := splitStatements(sql)
+
+	file := &fileState{createdTables: make(map[string]bool)}
+	findings := make([]Finding, 0)
+
+	for _, stmt := range statements {
+		for i := range Rules {
+			rule := &Rules[i]
+
+			if rule.Dialect != "" && config.Dialect != "" && rule.Dialect != config.Dialect {
+				continue
+			}
+
+			severity := config.severity(rule)
+			if severity == SeverityOff || stmt.ignored(rule.Name) || fileIgnored(fileSuppressions, rule.Name) {
+				continue
+			}
+
+			if rule.check(stmt, file) {
+				findings = append(findings, Finding{
+					File:     fileName,
+					Line:     stmt.Line,
+					Rule:     rule.Name,
+					Severity: severity,
+					Message:  rule.Message,
+				})
+			}
+		}
+
+		if match := createTableRe.FindStringSubmatch(stmt.Text); match != nil {
+			file.createdTables[normalizeName(match[1])] = true
+		}
+	}
+
+	return findings
+}
+
+func fileIgnored(suppressions []suppression, rule string) bool {
+	for _, s := range suppressions {
+		if len(s.rules) == 0 {
+			return true
+		}
+
+		for _, ignore := range s.rules {
+			if ignore == rule {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// alterClauses splits the actions of an ALTER TABLE statement, ignoring
+// commas inside parentheses.
+func alterClauses(text string) []string {
+	prefix := alterClausesRe.FindString(text)
+	if prefix == "" {
+		return nil
+	}
+
+	clauses := make([]string, 0)
+	depth, start := 0, len(prefix)
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				clauses = append(clauses, strings.TrimSpace(text[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(clauses, strings.TrimSpace(text[start:]))
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Trim(name, `"`+"`[]"))
+}
@@ -0,0 +1,133 @@
+package lint_test
+
+import (
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/lint"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		sql      string
+		config   lint.Config
+		expected []string
+	}{
+		{
+			desc:     "drop table",
+			sql:      "DROP TABLE users;",
+			expected: []string{"drop-table"},
+		},
+		{
+			desc:     "drop column",
+			sql:      "ALTER TABLE users DROP COLUMN name;",
+			expected: []string{"drop-column"},
+		},
+		{
+			desc:     "drop constraint isn't a dropped column",
+			sql:      "ALTER TABLE users DROP CONSTRAINT users_name_key;",
+			config:   lint.Config{Dialect: lint.DialectPostgres},
+			expected: []string{},
+		},
+		{
+			desc:     "not null column without default",
+			sql:      "ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL;",
+			expected: []string{"add-column-not-null"},
+		},
+		{
+			desc:     "not null column with default",
+			sql:      "ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT true;",
+			expected: []string{},
+		},
+		{
+			desc:     "not null column on a new table",
+			sql:      "CREATE TABLE IF NOT EXISTS users (id INT);\nALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL;",
+			expected: []string{},
+		},
+		{
+			desc:     "index without concurrently",
+			sql:      "CREATE INDEX IF NOT EXISTS users_name ON users (name);",
+			config:   lint.Config{Dialect: lint.DialectPostgres},
+			expected: []string{"create-index-concurrently"},
+		},
+		{
+			desc:     "index without concurrently on sqlite",
+			sql:      "CREATE INDEX IF NOT EXISTS users_name ON users (name);",
+			config:   lint.Config{Dialect: lint.DialectSqlite},
+			expected: []string{},
+		},
+		{
+			desc:     "type change",
+			sql:      "ALTER TABLE users ALTER COLUMN age TYPE BIGINT;",
+			config:   lint.Config{Dialect: lint.DialectPostgres},
+			expected: []string{"table-rewrite"},
+		},
+		{
+			desc:     "type change on sqlite",
+			sql:      "ALTER TABLE users ALTER COLUMN age TYPE BIGINT;",
+			config:   lint.Config{Dialect: lint.DialectSqlite},
+			expected: []string{"unsupported-alter"},
+		},
+		{
+			desc:     "update without where",
+			sql:      "UPDATE users SET name = 'WHERE';",
+			expected: []string{"missing-where"},
+		},
+		{
+			desc:     "delete with where",
+			sql:      "DELETE FROM users WHERE id = 1;",
+			expected: []string{},
+		},
+		{
+			desc:     "missing if not exists",
+			sql:      "CREATE TABLE users (id INT);",
+			expected: []string{"missing-if-not-exists"},
+		},
+		{
+			desc:     "function body",
+			sql:      "CREATE FUNCTION f() RETURNS void AS $$ BEGIN DELETE FROM users; END; $$ LANGUAGE plpgsql;",
+			expected: []string{},
+		},
+		{
+			desc:     "statement suppression",
+			sql:      "-- valkyrie-lint-ignore drop-table\nDROP TABLE users;\nDROP TABLE orgs;",
+			expected: []string{"drop-table"},
+		},
+		{
+			desc:     "trailing suppression",
+			sql:      "DROP TABLE users; -- valkyrie-lint-ignore replaced by accounts\nDROP TABLE orgs;",
+			expected: []string{"drop-table"},
+		},
+		{
+			desc:     "file suppression",
+			sql:      "-- valkyrie-lint-ignore-file drop-table\nDROP TABLE users;\nDROP TABLE orgs;",
+			expected: []string{},
+		},
+		{
+			desc:     "disabled rule",
+			sql:      "DROP TABLE users;",
+			config:   lint.Config{Severities: map[string]lint.Severity{"drop-table": lint.SeverityOff}},
+			expected: []string{},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			findings := lint.Lint("test.sql", tC.sql, tC.config)
+
+			rules := make([]string, len(findings))
+			for i, finding := range findings {
+				rules[i] = finding.Rule
+			}
+
+			if len(rules) != len(tC.expected) {
+				t.Fatalf("expected '%v', got '%v'", tC.expected, rules)
+			}
+			for i, rule := range rules {
+				if rule != tC.expected[i] {
+					t.Errorf("expected '%v', got '%v'", tC.expected[i], rule)
+				}
+			}
+		})
+	}
+}
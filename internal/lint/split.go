@@ -0,0 +1,203 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// statement is a sql statement with its comments removed, string literals
+// emptied and whitespace collapsed, so rules can match it with simple patterns.
+type statement struct {
+	Text string
+	Line int
+	// ignores are the rules suppressed by comments inside or right before
+	// the statement, ignoreAll is set by a suppression without rules.
+	ignores   []string
+	ignoreAll bool
+}
+
+func (stmt *statement) ignored(rule string) bool {
+	if stmt.ignoreAll {
+		return true
+	}
+
+	for _, ignore := range stmt.ignores {
+		if ignore == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	directiveRe  = regexp.MustCompile(`valkyrie-lint-ignore(-file)?\b[ \t]*([\w,-]*)`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+	dollarTagRe  = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+	triggerRe    = regexp.MustCompile(`(?i)^CREATE (TEMP |TEMPORARY )?TRIGGER\b`)
+	triggerEndRe = regexp.MustCompile(`(?i)\bEND$`)
+)
+
+// suppression is a parsed valkyrie-lint-ignore comment.
+type suppression struct {
+	file  bool
+	rules []string
+}
+
+func parseSuppression(comment string) *suppression {
+	match := directiveRe.FindStringSubmatch(comment)
+	if match == nil {
+		return nil
+	}
+
+	s := &suppression{file: match[1] != ""}
+	for _, rule := range strings.Split(match[2], ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			s.rules = append(s.rules, rule)
+		}
+	}
+
+	// anything that isn't a rule name is the reason, suppressing every rule
+	for _, rule := range s.rules {
+		if findRule(rule) == nil {
+			s.rules = nil
+			break
+		}
+	}
+
+	return s
+}
+
+// splitStatements splits a sql file into statements, returning the file
+// level suppressions separately. Semicolons in comments, strings, dollar
+// quoted bodies and SQLite trigger bodies don't end a statement.
+func splitStatements(sql string) ([]*statement, []suppression) {
+	statements := make([]*statement, 0)
+	fileSuppressions := make([]suppression, 0)
+
+	var buf strings.Builder
+	current := &statement{}
+	line := 1
+	lastEndLine := 0
+
+	addComment := func(comment string) {
+		s := parseSuppression(comment)
+		if s == nil {
+			return
+		} else if s.file {
+			fileSuppressions = append(fileSuppressions, *s)
+			return
+		}
+
+		// a comment after a statement on its line belongs to it
+		target := current
+		if strings.TrimSpace(buf.String()) == "" && line == lastEndLine && len(statements) > 0 {
+			target = statements[len(statements)-1]
+		}
+
+		if len(s.rules) == 0 {
+			target.ignoreAll = true
+		}
+		target.ignores = append(target.ignores, s.rules...)
+	}
+
+	endStatement := func() {
+		text := strings.TrimSpace(whitespaceRe.ReplaceAllString(buf.String(), " "))
+		if text != "" {
+			current.Text = text
+			statements = append(statements, current)
+			lastEndLine = line
+		} else if current.ignoreAll || len(current.ignores) > 0 {
+			// suppressions carry over empty statements to the next one
+			next := &statement{ignores: current.ignores, ignoreAll: current.ignoreAll}
+			buf.Reset()
+			current = next
+			return
+		}
+
+		buf.Reset()
+		current = &statement{}
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+
+		if current.Line == 0 && !isSpace(c) && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+			current.Line = line
+		}
+
+		switch {
+		case c == '\n':
+			line++
+			buf.WriteByte(c)
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				end = len(sql) - i
+			}
+			addComment(sql[i : i+end])
+			i += end - 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				end = len(sql) - i - 2
+			}
+			comment := sql[i : i+2+end]
+			addComment(comment)
+			line += strings.Count(comment, "\n")
+			i += end + 3
+			buf.WriteByte(' ')
+		case c == '\'':
+			// the literal's content can't match a rule
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == '\'' {
+					if j+1 < len(sql) && sql[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(sql) {
+				j = len(sql) - 1
+			}
+			line += strings.Count(sql[i:j+1], "\n")
+			buf.WriteString("''")
+			i = j
+		case c == '$' && (i == 0 || !isIdentChar(sql[i-1])):
+			tag := dollarTagRe.FindString(sql[i:])
+			if tag == "" {
+				buf.WriteByte(c)
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end == -1 {
+				end = len(sql) - i - len(tag)
+			}
+			line += strings.Count(sql[i:i+len(tag)+end], "\n")
+			buf.WriteString("$$")
+			i += len(tag) + end + len(tag) - 1
+		case c == ';':
+			text := strings.TrimSpace(whitespaceRe.ReplaceAllString(buf.String(), " "))
+			if triggerRe.MatchString(text) && !triggerEndRe.MatchString(text) {
+				buf.WriteByte(c)
+				continue
+			}
+			endStatement()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	endStatement()
+
+	return statements, fileSuppressions
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// GroupFilter selects migration groups by name. Patterns use path.Match glob
+// syntax. An empty Include matches every group, and Exclude wins over Include.
+type GroupFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate checks that every pattern is a valid glob.
+func (f GroupFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("(%s): invalid group pattern: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether the group passes the filter.
+func (f GroupFilter) Matches(groupName string) bool {
+	if matchAny(f.Exclude, groupName) {
+		return false
+	}
+
+	return len(f.Include) == 0 || matchAny(f.Include, groupName)
+}
+
+// Apply returns the directory entries whose name passes the filter.
+func (f GroupFilter) Apply(dirEntries []os.DirEntry) []os.DirEntry {
+	filtered := make([]os.DirEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		if f.Matches(entry.Name()) {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	return filtered
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// patterns are validated beforehand, so the error is ignored
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
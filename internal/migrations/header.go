@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// Header directives are comments at the top of a migration file overriding
+// the run's settings for that file, like:
+//
+//	-- valkyrie:statement-timeout 10m
+//	-- valkyrie:lock-timeout 5s
+//	-- valkyrie:pragma foreign_keys=OFF
+const (
+	directivePrefix           = "valkyrie:"
+	statementTimeoutDirective = "statement-timeout"
+	lockTimeoutDirective      = "lock-timeout"
+	pragmaDirective           = "pragma"
+)
+
+// TogglePragmas are the SQLite pragmas migrations can toggle. They're no-ops
+// inside a transaction, so they're set on the connection before the run's
+// transaction begins.
+var TogglePragmas = []string{"foreign_keys", "legacy_alter_table"}
+
+var pragmaValues = map[string]string{
+	"on":    "ON",
+	"true":  "ON",
+	"yes":   "ON",
+	"1":     "ON",
+	"off":   "OFF",
+	"false": "OFF",
+	"no":    "OFF",
+	"0":     "OFF",
+}
+
+// ParseTimeouts returns the timeouts of a migration file, the defaults
+// overridden by its header directives.
+func ParseTimeouts(content []byte, defaults models.Timeouts) (models.Timeouts, error) {
+	timeouts := defaults
+
+	err := parseHeader(content, func(directive string, value string) error {
+		var target *time.Duration
+		switch directive {
+		case statementTimeoutDirective:
+			target = &timeouts.Statement
+		case lockTimeoutDirective:
+			target = &timeouts.Lock
+		default:
+			return nil
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s': %v", directive, value, err)
+		}
+		*target = timeout
+
+		return nil
+	})
+
+	return timeouts, err
+}
+
+// ParsePragmas returns the pragmas toggled by the header directives of a
+// migration file, by their name, with ON or OFF values.
+func ParsePragmas(content []byte) (map[string]string, error) {
+	var pragmas map[string]string
+
+	err := parseHeader(content, func(directive string, value string) error {
+		if directive != pragmaDirective {
+			return nil
+		}
+
+		name, setting, found := strings.Cut(value, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !found || !helpers.Any(TogglePragmas, func(pragma string) bool { return pragma == name }) {
+			return fmt.Errorf("invalid pragma '%s', expected <name>=ON|OFF with one of: %s", value, strings.Join(TogglePragmas, ", "))
+		}
+
+		normalized, ok := pragmaValues[strings.ToLower(strings.TrimSpace(setting))]
+		if !ok {
+			return fmt.Errorf("invalid pragma '%s', expected ON or OFF", value)
+		}
+
+		if pragmas == nil {
+			pragmas = make(map[string]string)
+		}
+		pragmas[name] = normalized
+
+		return nil
+	})
+
+	return pragmas, err
+}
+
+// parseHeader calls fn with every directive of the leading comment lines of
+// a file.
+func parseHeader(content []byte, fn func(directive string, value string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		} else if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if !strings.HasPrefix(comment, directivePrefix) {
+			continue
+		}
+
+		directive, value, _ := strings.Cut(strings.TrimPrefix(comment, directivePrefix), " ")
+		if err := fn(directive, strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -14,40 +15,93 @@ import (
 
 const (
 	dateFmt = "20060102"
+	// downSuffix marks the script reverting the migration with the same name.
+	downSuffix = ".down.sql"
 )
 
+// Hook files run around a run when they're at the root of the migration
+// folder, or around a group when they're in its folder. The each hooks run
+// around every file, of every group or of their group.
+const (
+	BeforeHook     = "_before.sql"
+	AfterHook      = "_after.sql"
+	BeforeEachHook = "_before_each.sql"
+	AfterEachHook  = "_after_each.sql"
+)
+
+// Repeatable migrations have no date, they run after every versioned
+// migration whenever their content changes. They're either prefixed or in a
+// folder of their group.
+const (
+	RepeatablePrefix = "R_"
+	RepeatableFolder = "repeatable"
+)
+
+// IsRepeatable reports whether the file of a group folder is a repeatable migration.
+func IsRepeatable(fileName string) bool {
+	return strings.HasPrefix(fileName, RepeatablePrefix)
+}
+
+// IsHookFile reports whether the file is a hook instead of a migration.
+func IsHookFile(fileName string) bool {
+	switch fileName {
+	case BeforeHook, AfterHook, BeforeEachHook, AfterEachHook:
+		return true
+	}
+
+	return false
+}
+
 func GetMigrationGroups(migrationDir string, dirEntries []os.DirEntry) ([]*models.MigrationGroup, error) {
+	return GetMigrationGroupsFS(os.DirFS(migrationDir), dirEntries)
+}
+
+// GetMigrationGroupsFS reads the migration groups of dirEntries from the root of fsys.
+func GetMigrationGroupsFS(fsys fs.FS, dirEntries []fs.DirEntry) ([]*models.MigrationGroup, error) {
 	migrationGroups := make([]*models.MigrationGroup, 0)
 
 	for _, dir := range dirEntries {
 		dirName := dir.Name()
 
-		files, err := os.ReadDir(path.Join(migrationDir, dirName))
+		files, err := fs.ReadDir(fsys, dirName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read dir '%s' - %v", dirName, err)
 		}
 
 		group := models.MigrationGroup{
-			Name:           dirName,
-			MigrationCount: len(files),
-			Migrations:     []models.Migration{},
+			Name:       dirName,
+			Migrations: []models.Migration{},
 		}
 
 		if err := checkFileExtension(files, dirName); err != nil {
 			return nil, err
 		}
 
+		// down scripts aren't migrations, they're attached to the one they revert
+		downScripts := make(map[string]string)
+
 		for _, file := range files {
 			fileName := file.Name()
-			fileNameParts := strings.Split(fileName, "_")
 
-			if len(fileNameParts) < 2 {
-				return nil, fmt.Errorf("(%s): file name doesn't match expected format (yyyymmdd_description)", fileName)
+			if IsHookFile(fileName) || file.IsDir() {
+				continue
+			}
+
+			if IsRepeatable(fileName) {
+				group.Repeatables = append(group.Repeatables, models.Migration{
+					Name:      fileName,
+					GroupName: group.Name,
+				})
+				continue
+			}
+
+			if err := checkFileName(fileName); err != nil {
+				return nil, err
 			}
 
-			_, err := time.Parse(dateFmt, fileNameParts[0]) // yyyymmdd
-			if err != nil {
-				return nil, fmt.Errorf("(%s): file date doesn't match expected format (yyyymmdd)", fileName)
+			if IsDownScript(fileName) {
+				downScripts[strings.TrimSuffix(fileName, downSuffix)+".sql"] = fileName
+				continue
 			}
 
 			migration := models.Migration{
@@ -58,23 +112,146 @@ func GetMigrationGroups(migrationDir string, dirEntries []os.DirEntry) ([]*model
 			group.Migrations = append(group.Migrations, migration)
 		}
 
+		for i := range group.Migrations {
+			mig := &group.Migrations[i]
+			if downName, ok := downScripts[mig.Name]; ok {
+				mig.DownName = downName
+				delete(downScripts, mig.Name)
+			}
+		}
+
+		for _, downName := range downScripts {
+			return nil, fmt.Errorf("(%s/%s): down script has no matching migration", dirName, downName)
+		}
+
+		group.MigrationCount = len(group.Migrations)
+
+		repeatables, err := readRepeatableFolder(fsys, dirName, files)
+		if err != nil {
+			return nil, err
+		}
+		group.Repeatables = append(group.Repeatables, repeatables...)
+
 		migrationGroups = append(migrationGroups, &group)
 	}
 
 	return migrationGroups, nil
 }
 
+// readRepeatableFolder returns the migrations of the repeatable folder of a
+// group, named with the folder so they can be opened from the group.
+func readRepeatableFolder(fsys fs.FS, groupName string, groupFiles []fs.DirEntry) ([]models.Migration, error) {
+	isRepeatableFolder := func(entry fs.DirEntry) bool {
+		return entry.IsDir() && entry.Name() == RepeatableFolder
+	}
+
+	if !helpers.Any(groupFiles, isRepeatableFolder) {
+		return nil, nil
+	}
+
+	folder := path.Join(groupName, RepeatableFolder)
+	files, err := fs.ReadDir(fsys, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dir '%s' - %v", folder, err)
+	}
+
+	if err := checkFileExtension(files, folder); err != nil {
+		return nil, err
+	}
+
+	repeatables := make([]models.Migration, 0, len(files))
+	for _, file := range files {
+		repeatables = append(repeatables, models.Migration{
+			Name:      path.Join(RepeatableFolder, file.Name()),
+			GroupName: groupName,
+		})
+	}
+
+	return repeatables, nil
+}
+
+// ParseMigrationRef splits a '<group>/<file>' reference into its group and
+// file names, validating the file name the same way migration folders are.
+func ParseMigrationRef(ref string) (groupName string, fileName string, err error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("(%s): migration reference doesn't match expected format (<group>/<file>)", ref)
+	}
+
+	groupName, fileName = parts[0], parts[1]
+
+	if path.Ext(fileName) != ".sql" {
+		return "", "", fmt.Errorf("(%s): migration file must be a sql file", ref)
+	}
+
+	if IsDownScript(fileName) {
+		return "", "", fmt.Errorf("(%s): migration reference can't be a down script", ref)
+	}
+
+	if err := checkFileName(fileName); err != nil {
+		return "", "", err
+	}
+
+	return groupName, fileName, nil
+}
+
+// IsDownScript reports whether the file reverts a migration instead of being one.
+func IsDownScript(fileName string) bool {
+	return strings.HasSuffix(fileName, downSuffix)
+}
+
+// MigrationDate returns the yyyymmdd prefix of a migration file name. Dates
+// compare correctly as strings.
+func MigrationDate(fileName string) string {
+	date, _, _ := strings.Cut(fileName, "_")
+	return date
+}
+
+func checkFileName(fileName string) error {
+	fileNameParts := strings.Split(fileName, "_")
+
+	if len(fileNameParts) < 2 {
+		return fmt.Errorf("(%s): file name doesn't match expected format (yyyymmdd_description)", fileName)
+	}
+
+	if err := CheckDate(fileNameParts[0]); err != nil {
+		return fmt.Errorf("(%s): file date doesn't match expected format (yyyymmdd)", fileName)
+	}
+
+	return nil
+}
+
+var descriptionRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// CheckDescription validates the description of a new migration, what follows
+// the date in its file name. It takes letters, digits, '_' and '-', so it
+// can't reach outside the group folder or be taken for a down script.
+func CheckDescription(description string) error {
+	if !descriptionRe.MatchString(description) {
+		return fmt.Errorf("migration description '%s' may only contain letters, digits, '_' and '-'", description)
+	}
+
+	return nil
+}
+
+// CheckDate validates a yyyymmdd date.
+func CheckDate(date string) error {
+	_, err := time.Parse(dateFmt, date)
+	return err
+}
+
 func checkFileExtension(migrationGroupFiles []fs.DirEntry, folderName string) error {
-	isDir := func(entry os.DirEntry) bool {
-		return entry.IsDir()
+	// only the repeatable folder of a group may be nested
+	isNestedDir := func(entry os.DirEntry) bool {
+		return entry.IsDir() && (entry.Name() != RepeatableFolder || strings.Contains(folderName, "/"))
 	}
 
-	if helpers.Any(migrationGroupFiles, isDir) {
+	if helpers.Any(migrationGroupFiles, isNestedDir) {
 		return fmt.Errorf("migration group folder may not contain nested subfolders. (%s)", folderName)
 	}
 
 	isNotSql := func(file os.DirEntry) bool {
-		return path.Ext(file.Name()) != ".sql"
+		return !file.IsDir() && path.Ext(file.Name()) != ".sql"
 	}
 
 	if helpers.Any(migrationGroupFiles, isNotSql) {
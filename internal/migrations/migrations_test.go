@@ -6,8 +6,11 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
 )
 
 func getTestDirPath() string {
@@ -85,3 +88,285 @@ func TestGetMigrations2(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMigrationRef(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		ref           string
+		expectedErr   bool
+		expectedGroup string
+		expectedFile  string
+	}{
+		{
+			desc:          "valid reference",
+			ref:           "Entity/20240310_cr.sql",
+			expectedGroup: "Entity",
+			expectedFile:  "20240310_cr.sql",
+		},
+		{
+			desc:        "missing group",
+			ref:         "20240310_cr.sql",
+			expectedErr: true,
+		},
+		{
+			desc:        "nested path",
+			ref:         "Entity/sub/20240310_cr.sql",
+			expectedErr: true,
+		},
+		{
+			desc:        "invalid date fmt",
+			ref:         "Entity/240310_cr.sql",
+			expectedErr: true,
+		},
+		{
+			desc:        "not a sql file",
+			ref:         "Entity/20240310_cr.txt",
+			expectedErr: true,
+		},
+		{
+			desc:        "down script",
+			ref:         "Entity/20240310_cr.down.sql",
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			group, file, err := migrations.ParseMigrationRef(tC.ref)
+
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+				return
+			}
+
+			if group != tC.expectedGroup || file != tC.expectedFile {
+				t.Errorf("expected '%s/%s', got '%s/%s'", tC.expectedGroup, tC.expectedFile, group, file)
+			}
+		})
+	}
+}
+
+func TestGroupFilter(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		filter   migrations.GroupFilter
+		group    string
+		expected bool
+	}{
+		{
+			desc:     "empty filter",
+			filter:   migrations.GroupFilter{},
+			group:    "Users",
+			expected: true,
+		},
+		{
+			desc:     "included by glob",
+			filter:   migrations.GroupFilter{Include: []string{"Billing*", "Users"}},
+			group:    "BillingInvoices",
+			expected: true,
+		},
+		{
+			desc:     "not included",
+			filter:   migrations.GroupFilter{Include: []string{"Billing*"}},
+			group:    "Users",
+			expected: false,
+		},
+		{
+			desc:     "exclude wins",
+			filter:   migrations.GroupFilter{Include: []string{"*"}, Exclude: []string{"Legacy?"}},
+			group:    "Legacy1",
+			expected: false,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if result := tC.filter.Matches(tC.group); result != tC.expected {
+				t.Errorf("%s - expected '%v', got '%v'", tC.desc, tC.expected, result)
+			}
+		})
+	}
+}
+
+func TestRepeatableMigrations(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		fsys                fstest.MapFS
+		expectedErr         bool
+		expectedMigrations  int
+		expectedRepeatables []string
+	}{
+		{
+			desc: "prefixed files",
+			fsys: fstest.MapFS{
+				"core/20240101_t.sql": {Data: []byte("CREATE TABLE t (id INTEGER);")},
+				"core/R_view.sql":     {Data: []byte("CREATE VIEW v AS SELECT 1;")},
+			},
+			expectedMigrations:  1,
+			expectedRepeatables: []string{"R_view.sql"},
+		},
+		{
+			desc: "repeatable folder",
+			fsys: fstest.MapFS{
+				"core/20240101_t.sql":        {Data: []byte("CREATE TABLE t (id INTEGER);")},
+				"core/repeatable/views.sql":  {Data: []byte("CREATE VIEW v AS SELECT 1;")},
+				"core/repeatable/grants.sql": {Data: []byte("SELECT 1;")},
+			},
+			expectedMigrations:  1,
+			expectedRepeatables: []string{"repeatable/grants.sql", "repeatable/views.sql"},
+		},
+		{
+			desc: "other subfolder",
+			fsys: fstest.MapFS{
+				"core/other/views.sql": {Data: []byte("CREATE VIEW v AS SELECT 1;")},
+			},
+			expectedErr: true,
+		},
+		{
+			desc: "nested repeatable folder",
+			fsys: fstest.MapFS{
+				"core/repeatable/nested/views.sql": {Data: []byte("CREATE VIEW v AS SELECT 1;")},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			entries, err := tC.fsys.ReadDir(".")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			groups, err := migrations.GetMigrationGroupsFS(tC.fsys, entries)
+			if tC.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			group := groups[0]
+			if group.MigrationCount != tC.expectedMigrations {
+				t.Errorf("expected '%v', got '%v'", tC.expectedMigrations, group.MigrationCount)
+			}
+
+			if len(group.Repeatables) != len(tC.expectedRepeatables) {
+				t.Fatalf("expected '%v', got '%v'", tC.expectedRepeatables, group.Repeatables)
+			}
+			for i, mig := range group.Repeatables {
+				if mig.Name != tC.expectedRepeatables[i] {
+					t.Errorf("expected '%v', got '%v'", tC.expectedRepeatables[i], mig.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	defaults := models.Timeouts{Statement: time.Minute, Lock: time.Second}
+
+	testCases := []struct {
+		desc        string
+		content     string
+		expected    models.Timeouts
+		expectedErr bool
+	}{
+		{
+			desc:     "no header",
+			content:  "CREATE TABLE users (id INTEGER);",
+			expected: defaults,
+		},
+		{
+			desc:     "both directives",
+			content:  "-- valkyrie:statement-timeout 10m\n-- valkyrie:lock-timeout 5s\nCREATE INDEX idx ON users (id);",
+			expected: models.Timeouts{Statement: 10 * time.Minute, Lock: 5 * time.Second},
+		},
+		{
+			desc:     "directive after the header",
+			content:  "-- builds the index\nCREATE INDEX idx ON users (id);\n-- valkyrie:lock-timeout 5s",
+			expected: defaults,
+		},
+		{
+			desc:     "disabled timeout",
+			content:  "\n-- valkyrie:statement-timeout 0\n",
+			expected: models.Timeouts{Lock: time.Second},
+		},
+		{
+			desc:        "invalid duration",
+			content:     "-- valkyrie:lock-timeout soon",
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			timeouts, err := migrations.ParseTimeouts([]byte(tC.content), defaults)
+
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+				return
+			}
+
+			if !tC.expectedErr && timeouts != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, timeouts)
+			}
+		})
+	}
+}
+
+func TestParsePragmas(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		content     string
+		expected    map[string]string
+		expectedErr bool
+	}{
+		{
+			desc:     "no header",
+			content:  "CREATE TABLE users (id INTEGER);",
+			expected: map[string]string{},
+		},
+		{
+			desc:     "toggles",
+			content:  "-- valkyrie:pragma foreign_keys=OFF\n-- valkyrie:pragma legacy_alter_table = on\nDROP TABLE users;",
+			expected: map[string]string{"foreign_keys": "OFF", "legacy_alter_table": "ON"},
+		},
+		{
+			desc:        "unsupported pragma",
+			content:     "-- valkyrie:pragma journal_mode=WAL",
+			expectedErr: true,
+		},
+		{
+			desc:        "invalid value",
+			content:     "-- valkyrie:pragma foreign_keys=maybe",
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			pragmas, err := migrations.ParsePragmas([]byte(tC.content))
+
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+				return
+			}
+
+			if tC.expectedErr {
+				return
+			}
+
+			if len(pragmas) != len(tC.expected) {
+				t.Errorf("expected '%v', got '%v'", tC.expected, pragmas)
+			}
+			for name, value := range tC.expected {
+				if pragmas[name] != value {
+					t.Errorf("expected '%v', got '%v'", tC.expected, pragmas)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// ResetSeed runs before the seeds of its profile when they're reset, it
+// should delete the data they insert.
+const ResetSeed = "_reset.sql"
+
+// GetSeedsFS returns the seeds of a profile folder at the root of fsys,
+// sorted by name, and whether the profile has a reset script.
+func GetSeedsFS(fsys fs.FS, profile string) ([]models.Seed, bool, error) {
+	files, err := fs.ReadDir(fsys, profile)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read seed profile '%s' - %v", profile, err)
+	}
+
+	seeds := make([]models.Seed, 0, len(files))
+	hasReset := false
+
+	for _, file := range files {
+		fileName := file.Name()
+
+		if file.IsDir() {
+			return nil, false, fmt.Errorf("seed profile folder may not contain nested subfolders. (%s)", profile)
+		} else if path.Ext(fileName) != ".sql" {
+			return nil, false, fmt.Errorf("seed profile folder may only contain sql files. (%s)", profile)
+		}
+
+		if fileName == ResetSeed {
+			hasReset = true
+			continue
+		}
+
+		seeds = append(seeds, models.Seed{
+			Profile: profile,
+			Name:    fileName,
+		})
+	}
+
+	return seeds, hasReset, nil
+}
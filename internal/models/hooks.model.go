@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ExecutionHooks are called by ExecuteMigrations inside its transaction,
+// around the run, every group and every file. An error aborts the run.
+type ExecutionHooks interface {
+	BeforeRun(tx *sql.Tx) error
+	AfterRun(tx *sql.Tx) error
+	BeforeGroup(tx *sql.Tx, group *MigrationGroup) error
+	AfterGroup(tx *sql.Tx, group *MigrationGroup) error
+	BeforeFile(tx *sql.Tx, mig *Migration) error
+	// AfterFile gets how long the file took to execute.
+	AfterFile(tx *sql.Tx, mig *Migration, duration time.Duration) error
+	// FileFailed is called when a file fails to execute, before the run is aborted.
+	FileFailed(mig *Migration, err error)
+}
+
+// NoHooks runs nothing, it's used when ExecuteMigrations gets nil hooks.
+type NoHooks struct{}
+
+func (NoHooks) BeforeRun(tx *sql.Tx) error                          { return nil }
+func (NoHooks) AfterRun(tx *sql.Tx) error                           { return nil }
+func (NoHooks) BeforeGroup(tx *sql.Tx, group *MigrationGroup) error { return nil }
+func (NoHooks) AfterGroup(tx *sql.Tx, group *MigrationGroup) error  { return nil }
+func (NoHooks) BeforeFile(tx *sql.Tx, mig *Migration) error         { return nil }
+
+func (NoHooks) AfterFile(tx *sql.Tx, mig *Migration, duration time.Duration) error { return nil }
+
+func (NoHooks) FileFailed(mig *Migration, err error) {}
+
+// HookCommands are shell commands run around migrations, read from the
+// connection config file.
+type HookCommands struct {
+	BeforeRun   []string
+	AfterRun    []string
+	BeforeGroup []string
+	AfterGroup  []string
+	BeforeFile  []string
+	AfterFile   []string
+}
+
+// HasCommands reports whether any hook has commands to run.
+func (commands HookCommands) HasCommands() bool {
+	return len(commands.BeforeRun) > 0 || len(commands.AfterRun) > 0 ||
+		len(commands.BeforeGroup) > 0 || len(commands.AfterGroup) > 0 ||
+		len(commands.BeforeFile) > 0 || len(commands.AfterFile) > 0
+}
@@ -1,7 +1,15 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"io"
+	"time"
+)
+
+var (
+	ErrMigrationAlreadyApplied = errors.New("migration is already applied")
+	ErrMigrationNotApplied     = errors.New("migration is not applied")
 )
 
 type MigrationGroup struct {
@@ -10,6 +18,9 @@ type MigrationGroup struct {
 	Files          []io.Reader
 	Migrations     []Migration
 	MigrationCount int `db:"migrationCount"`
+	// Repeatables run after every versioned migration, whenever their
+	// checksum changes.
+	Repeatables []Migration
 }
 
 func (mg *MigrationGroup) AddFile(f io.Reader) {
@@ -32,14 +43,86 @@ func (mg *MigrationGroup) AddMigration(mig Migration) {
 	}
 }
 
+const (
+	MarkStatusApplied = "applied"
+	MarkStatusPending = "pending"
+	// MarkStatusSquashed is logged for migrations replaced by a baseline.
+	MarkStatusSquashed = "squashed"
+)
+
 type Migration struct {
 	Name      string
 	GroupName string `db:"groupName"`
 	FReader   io.Reader
+	// DownName is the script reverting the migration, empty when it has none.
+	DownName string
+	// Checksum identifies the content of a repeatable migration.
+	Checksum string
+	// Timeouts apply while the file executes, zero values set no limit.
+	Timeouts Timeouts
+	// Pragmas are SQLite pragmas set outside the run's transaction, like
+	// foreign_keys=OFF for rebuilding tables, by their name.
+	Pragmas map[string]string
+}
+
+// Timeouts limit how long a statement may run and wait for locks.
+type Timeouts struct {
+	Statement time.Duration
+	Lock      time.Duration
+}
+
+// RepeatableKey identifies a repeatable migration in the checksums returned
+// by GetRepeatableChecksums.
+func RepeatableKey(groupName, name string) string {
+	return groupName + "/" + name
+}
+
+const (
+	IssueMissingTable       = "missing table"
+	IssueDuplicateGroup     = "duplicate group"
+	IssueOrphanedMigration  = "orphaned migration"
+	IssueDuplicateMigration = "duplicate migration"
+	IssueInvalidTimestamp   = "invalid timestamp"
+)
+
+// TrackingIssue describes an inconsistency found in the migration tracking tables.
+type TrackingIssue struct {
+	Kind   string
+	Detail string
+}
+
+// AppliedMark is a migration MarkAllApplied logs as executed.
+type AppliedMark struct {
+	GroupName     string
+	MigrationName string
+	Reason        string
 }
 
 type MigrationStorer interface {
 	EnsureCreated() error
+	// MissingTables returns the tracking tables EnsureCreated would create,
+	// without creating them.
+	MissingTables() ([]string, error)
 	GetMigrations() ([]MigrationGroup, error)
-	ExecuteMigrations([]*MigrationGroup) error 
-}
\ No newline at end of file
+	// ExecuteMigrations applies the groups in a single transaction, calling the
+	// hooks around the run, every group and every file. Hooks may be nil.
+	// Cancelling ctx rolls the transaction back.
+	ExecuteMigrations(ctx context.Context, groups []*MigrationGroup, hooks ExecutionHooks) error
+	// MarkApplied logs a migration as executed without running it.
+	MarkApplied(groupName, migrationName, reason string) error
+	// MarkAllApplied logs the migrations as executed in a single transaction,
+	// skipping the ones already logged. It returns the marks it logged.
+	MarkAllApplied(marks []AppliedMark) ([]AppliedMark, error)
+	// MarkPending removes a migration from the log so it runs again on the next migrate.
+	MarkPending(groupName, migrationName, reason string) error
+	// Squash replaces the logged squashed migrations of a group with the
+	// baseline migration in a single transaction.
+	Squash(groupName string, squashed []string, baseline string, reason string) error
+	// GetRepeatableChecksums returns the checksum every repeatable migration
+	// last ran with, by RepeatableKey.
+	GetRepeatableChecksums() (map[string]string, error)
+	// Diagnose reports inconsistencies in the migration tracking tables.
+	Diagnose() ([]TrackingIssue, error)
+	// Repair fixes the inconsistencies reported by Diagnose in a single transaction.
+	Repair() ([]TrackingIssue, error)
+}
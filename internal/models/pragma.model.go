@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SqlitePragmas are set on every connection to a SQLite database, empty
+// values keep SQLite's defaults.
+type SqlitePragmas struct {
+	// ForeignKeys enforces foreign keys when on.
+	ForeignKeys string
+	// JournalMode is delete, truncate, persist, memory, wal or off.
+	JournalMode string
+	// Synchronous is off, normal, full or extra.
+	Synchronous string
+	// BusyTimeout is how long statements wait for a locked database.
+	BusyTimeout time.Duration
+}
+
+func (pragmas SqlitePragmas) IsZero() bool {
+	return pragmas == SqlitePragmas{}
+}
@@ -0,0 +1,28 @@
+package models
+
+import "io"
+
+// Seed is a data file of an environment profile, tracked apart from the
+// schema migrations so it can be re-run or reset.
+type Seed struct {
+	Profile  string
+	Name     string
+	Checksum string
+	FReader  io.Reader
+}
+
+// SeedKey identifies a seed in the checksums returned by GetSeedChecksums.
+func SeedKey(profile, name string) string {
+	return profile + "/" + name
+}
+
+type SeedStorer interface {
+	// EnsureSeedsCreated creates the seed tracking table.
+	EnsureSeedsCreated() error
+	// GetSeedChecksums returns the checksum every seed last ran with, by SeedKey.
+	GetSeedChecksums() (map[string]string, error)
+	// ExecuteSeeds runs the reset scripts, forgetting the seeds of their
+	// profiles, and then the seeds in a single transaction. Reset scripts
+	// without a reader only forget the seeds.
+	ExecuteSeeds(resets []Seed, seeds []Seed) error
+}
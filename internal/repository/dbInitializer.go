@@ -3,22 +3,94 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
 )
 
+// table definitions match the ones in db/schema/sqlite
+const (
+	migrationGroupTableCmd = `CREATE TABLE IF NOT EXISTS migration_group (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(255) NOT NULL
+	);`
+
+	migrationTableCmd = `CREATE TABLE IF NOT EXISTS migration (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		migration_group_id INTEGER NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		executed_at TIMESTAMP NOT NULL
+	);`
+
+	migrationMarkTableCmd = `CREATE TABLE IF NOT EXISTS migration_mark (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_name VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		reason TEXT NOT NULL,
+		marked_at TIMESTAMP NOT NULL
+	);`
+
+	repeatableMigrationTableCmd = `CREATE TABLE IF NOT EXISTS repeatable_migration (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_name VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		executed_at TIMESTAMP NOT NULL
+	);`
+
+	seedTableCmd = `CREATE TABLE IF NOT EXISTS seed_run (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		profile VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		executed_at TIMESTAMP NOT NULL,
+		UNIQUE (profile, name)
+	);`
+)
+
+// TrackingTablesDDL returns the statements creating the tracking tables, in order.
+func TrackingTablesDDL() []string {
+	return []string{migrationGroupTableCmd, migrationTableCmd, migrationMarkTableCmd, repeatableMigrationTableCmd}
+}
+
+var migrationTables = []string{
+	"migration_group",
+	"migration",
+	"migration_mark",
+	"repeatable_migration",
+}
+
+// EnsureSeedsCreated creates the seed tracking table, which isn't part of
+// the migration tracking tables.
+func EnsureSeedsCreated(db Querier) error {
+	_, err := db.Exec(seedTableCmd)
+	return err
+}
+
 func EnsureCreated(db *sql.DB) error {
-	migrationTables := []string{
-		"migration_group",
-		"migration",
+	foundTables, err := FindMigrationTables(db)
+	if err != nil {
+		return err
 	}
 
+	if len(foundTables) == len(migrationTables) {
+		fmt.Println("migrations tables exist")
+		return nil
+	}
+
+	return createMissingTables(db, foundTables)
+}
+
+// FindMigrationTables returns which of the tracking tables exist in the database.
+func FindMigrationTables(db Querier) ([]string, error) {
 	query := `SELECT name 
 		FROM sqlite_master 
 		WHERE type='table' 
-			AND name IN ($1, $2)`
+			AND name IN ($1, $2, $3, $4)`
 
-	rows, err := db.Query(query, migrationTables[0], migrationTables[1])
+	rows, err := db.Query(query, migrationTables[0], migrationTables[1], migrationTables[2], migrationTables[3])
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer rows.Close()
@@ -27,27 +99,52 @@ func EnsureCreated(db *sql.DB) error {
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
-			return err
+			return nil, err
 		}
 
 		foundTables = append(foundTables, name)
 	}
 
-	if len(foundTables) == len(migrationTables) {
-		fmt.Println("migrations tables exist")
-		return nil
+	return foundTables, rows.Err()
+}
+
+// MissingMigrationTables returns the tracking tables not included in foundTables.
+func MissingMigrationTables(foundTables []string) []string {
+	missing := make([]string, 0)
+	for _, table := range migrationTables {
+		if !sliceContains(foundTables, table) {
+			missing = append(missing, table)
+		}
 	}
 
+	return missing
+}
+
+func createMissingTables(db Querier, foundTables []string) error {
 	// create migration_group table
 	if !sliceContains(foundTables, migrationTables[0]) {
-		if err = createMigrationGroupTable(db); err != nil {
+		if err := createMigrationGroupTable(db); err != nil {
 			return err
 		}
 	}
 
 	// create migration table
 	if !sliceContains(foundTables, migrationTables[1]) {
-		if err = createMigrationTable(db); err != nil {
+		if err := createMigrationTable(db); err != nil {
+			return err
+		}
+	}
+
+	// create migration_mark table
+	if !sliceContains(foundTables, migrationTables[2]) {
+		if err := createMigrationMarkTable(db); err != nil {
+			return err
+		}
+	}
+
+	// create repeatable_migration table
+	if !sliceContains(foundTables, migrationTables[3]) {
+		if err := createRepeatableMigrationTable(db); err != nil {
 			return err
 		}
 	}
@@ -65,43 +162,104 @@ func sliceContains(slice []string, s string) bool {
 	return false
 }
 
-func createMigrationGroupTable(db *sql.DB) error {
+func createMigrationGroupTable(db Querier) error {
 	fmt.Println("creating table 'migration_group'...")
 
-	// buf, err := os.ReadFile("./db/schema/sqlite/cr_migrationGroup.sql")
-	// if err != nil {
-	// 	return err
-	// }
-	cmd := `CREATE TABLE migration_group (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name VARCHAR(255) NOT NULL
-	);`
-
-	if _, sqlErr := db.Exec(cmd); sqlErr != nil {
+	if _, sqlErr := db.Exec(migrationGroupTableCmd); sqlErr != nil {
 		return sqlErr
 	}
 
 	return nil
 }
 
-func createMigrationTable(db *sql.DB) error {
+func createMigrationTable(db Querier) error {
 	fmt.Println(`creating table 'migration'...`)
 
-	// buf, err := os.ReadFile("./db/schema/sqlite/cr_migration.sql")
-	// if err != nil {
-	// 	return err
-	// }
-	cmd := `CREATE TABLE migration (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		migration_group_id INTEGER NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		executed_at TIMESTAMP NOT NULL
-	);`
+	if _, sqlErr := db.Exec(migrationTableCmd); sqlErr != nil {
+		return sqlErr
+	}
 
+	return nil
+}
 
-	if _, sqlErr := db.Exec(cmd); sqlErr != nil {
+func createMigrationMarkTable(db Querier) error {
+	fmt.Println(`creating table 'migration_mark'...`)
+
+	if _, sqlErr := db.Exec(migrationMarkTableCmd); sqlErr != nil {
 		return sqlErr
 	}
 
 	return nil
 }
+
+func createRepeatableMigrationTable(db Querier) error {
+	fmt.Println(`creating table 'repeatable_migration'...`)
+
+	if _, sqlErr := db.Exec(repeatableMigrationTableCmd); sqlErr != nil {
+		return sqlErr
+	}
+
+	return nil
+}
+
+// SQLite stores timestamps as text, so anything datetime() can't parse is invalid.
+const invalidTimestampCond = `(executed_at IS NULL
+	OR datetime(executed_at) IS NULL
+	OR datetime(executed_at) > datetime('now', '+1 day'))`
+
+// Diagnose reports missing tracking tables and, when the core tables exist,
+// inconsistencies in their contents.
+func Diagnose(db *sql.DB) ([]models.TrackingIssue, error) {
+	foundTables, err := FindMigrationTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]models.TrackingIssue, 0)
+	for _, table := range MissingMigrationTables(foundTables) {
+		issues = append(issues, models.TrackingIssue{
+			Kind:   models.IssueMissingTable,
+			Detail: fmt.Sprintf("table '%s' doesn't exist", table),
+		})
+	}
+
+	if !sliceContains(foundTables, migrationTables[0]) || !sliceContains(foundTables, migrationTables[1]) {
+		return issues, nil
+	}
+
+	contentIssues, err := DiagnoseTracking(db, invalidTimestampCond)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(issues, contentIssues...), nil
+}
+
+// Repair creates missing tracking tables and fixes their contents in a single transaction.
+func Repair(db *sql.DB) ([]models.TrackingIssue, error) {
+	issues, err := Diagnose(db)
+	if err != nil || len(issues) == 0 {
+		return issues, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	foundTables, err := FindMigrationTables(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createMissingTables(tx, foundTables); err != nil {
+		return nil, err
+	}
+
+	if err := RepairTracking(tx, invalidTimestampCond); err != nil {
+		return nil, err
+	}
+
+	return issues, tx.Commit()
+}
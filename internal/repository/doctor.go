@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// Querier is satisfied by both *sql.DB and *sql.Tx.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// DiagnoseTracking checks the contents of the tracking tables. Both tables must
+// exist. invalidTimestampCond is a dialect specific condition on
+// migration.executed_at matching null or nonsensical values.
+func DiagnoseTracking(db Querier, invalidTimestampCond string) ([]models.TrackingIssue, error) {
+	issues := make([]models.TrackingIssue, 0)
+
+	checks := []struct {
+		kind   string
+		query  string
+		detail func(rows *sql.Rows) (string, error)
+	}{
+		{
+			kind: models.IssueDuplicateGroup,
+			query: `SELECT name, count(1)
+				FROM migration_group
+				GROUP BY name
+				HAVING count(1) > 1`,
+			detail: func(rows *sql.Rows) (string, error) {
+				var name string
+				var count int
+				err := rows.Scan(&name, &count)
+				return fmt.Sprintf("group '%s' is logged %v times", name, count), err
+			},
+		},
+		{
+			kind: models.IssueOrphanedMigration,
+			query: `SELECT m.id, m.migration_group_id, m.name
+				FROM migration m
+					LEFT JOIN migration_group mg on mg.id = m.migration_group_id
+				WHERE mg.id IS NULL`,
+			detail: func(rows *sql.Rows) (string, error) {
+				var id, groupId int64
+				var name string
+				err := rows.Scan(&id, &groupId, &name)
+				return fmt.Sprintf("migration '%s' (id %v) references missing group id %v", name, id, groupId), err
+			},
+		},
+		{
+			kind: models.IssueDuplicateMigration,
+			query: `SELECT mg.name, m.name, count(1)
+				FROM migration m
+					JOIN migration_group mg on mg.id = m.migration_group_id
+				GROUP BY m.migration_group_id, mg.name, m.name
+				HAVING count(1) > 1`,
+			detail: func(rows *sql.Rows) (string, error) {
+				var groupName, name string
+				var count int
+				err := rows.Scan(&groupName, &name, &count)
+				return fmt.Sprintf("migration '%s/%s' is logged %v times", groupName, name, count), err
+			},
+		},
+		{
+			kind: models.IssueInvalidTimestamp,
+			query: `SELECT m.id, m.name
+				FROM migration m
+				WHERE ` + invalidTimestampCond,
+			detail: func(rows *sql.Rows) (string, error) {
+				var id int64
+				var name string
+				err := rows.Scan(&id, &name)
+				return fmt.Sprintf("migration '%s' (id %v) has a null or invalid executed_at", name, id), err
+			},
+		},
+	}
+
+	for _, check := range checks {
+		rows, err := db.Query(check.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for %s: %v", check.kind, err)
+		}
+
+		for rows.Next() {
+			detail, err := check.detail(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			issues = append(issues, models.TrackingIssue{Kind: check.kind, Detail: detail})
+		}
+
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return issues, nil
+}
+
+// RepairTracking fixes the issues found by DiagnoseTracking. Duplicate groups
+// are merged into the oldest one before duplicate migrations are removed, so
+// files logged under both copies of a group are only kept once.
+func RepairTracking(tx *sql.Tx, invalidTimestampCond string) error {
+	cmds := []string{
+		// point migrations of duplicate groups to the first group with that name
+		`UPDATE migration
+		SET migration_group_id = (
+			SELECT min(g2.id)
+			FROM migration_group g1
+				JOIN migration_group g2 on g1.name = g2.name
+			WHERE g1.id = migration.migration_group_id
+		)
+		WHERE migration_group_id IN (
+			SELECT g.id
+			FROM migration_group g
+			WHERE g.id > (SELECT min(g2.id) FROM migration_group g2 WHERE g2.name = g.name)
+		)`,
+		`DELETE FROM migration_group
+		WHERE id > (SELECT min(g2.id) FROM migration_group g2 WHERE g2.name = migration_group.name)`,
+		`DELETE FROM migration
+		WHERE migration_group_id NOT IN (SELECT id FROM migration_group)`,
+		`DELETE FROM migration
+		WHERE id > (
+			SELECT min(m2.id)
+			FROM migration m2
+			WHERE m2.migration_group_id = migration.migration_group_id
+				AND m2.name = migration.name
+		)`,
+	}
+
+	for _, cmd := range cmds {
+		if _, err := tx.Exec(cmd); err != nil {
+			return err
+		}
+	}
+
+	// rows with unusable timestamps are stamped with the repair time
+	_, err := tx.Exec(`UPDATE migration SET executed_at = $1 WHERE `+invalidTimestampCond, time.Now())
+	return err
+}
@@ -0,0 +1,153 @@
+package repository_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/internal/repository"
+)
+
+const validTimestamp = "'2024-01-01 10:00:00'"
+
+func TestDiagnoseRepair(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		setup          []string
+		expectedIssues map[string]int
+		// expectedAfter are queries counting rows, by their expected count
+		// once the issues are repaired
+		expectedAfter map[string]int
+	}{
+		{
+			desc:           "healthy",
+			setup:          []string{"INSERT INTO migration_group (id, name) VALUES (1, 'Users')", "INSERT INTO migration (migration_group_id, name, executed_at) VALUES (1, 'a.sql', " + validTimestamp + ")"},
+			expectedIssues: map[string]int{},
+			expectedAfter:  map[string]int{"SELECT count(*) FROM migration": 1},
+		},
+		{
+			desc: "duplicate groups are merged",
+			setup: []string{
+				"INSERT INTO migration_group (id, name) VALUES (1, 'Users'), (2, 'Users')",
+				"INSERT INTO migration (migration_group_id, name, executed_at) VALUES (1, 'a.sql', " + validTimestamp + "), (2, 'a.sql', " + validTimestamp + "), (2, 'b.sql', " + validTimestamp + ")",
+			},
+			expectedIssues: map[string]int{models.IssueDuplicateGroup: 1},
+			expectedAfter: map[string]int{
+				"SELECT count(*) FROM migration_group":                        1,
+				"SELECT count(*) FROM migration WHERE migration_group_id = 1": 2,
+				"SELECT count(*) FROM migration WHERE name = 'a.sql'":         1,
+				"SELECT count(*) FROM migration WHERE migration_group_id = 2": 0,
+			},
+		},
+		{
+			desc: "orphaned migrations are removed",
+			setup: []string{
+				"INSERT INTO migration_group (id, name) VALUES (1, 'Users')",
+				"INSERT INTO migration (migration_group_id, name, executed_at) VALUES (1, 'a.sql', " + validTimestamp + "), (9, 'x.sql', " + validTimestamp + ")",
+			},
+			expectedIssues: map[string]int{models.IssueOrphanedMigration: 1},
+			expectedAfter: map[string]int{
+				"SELECT count(*) FROM migration":                      1,
+				"SELECT count(*) FROM migration WHERE name = 'x.sql'": 0,
+			},
+		},
+		{
+			desc: "duplicate migrations keep the first row",
+			setup: []string{
+				"INSERT INTO migration_group (id, name) VALUES (1, 'Roles')",
+				"INSERT INTO migration (id, migration_group_id, name, executed_at) VALUES (1, 1, 'r.sql', " + validTimestamp + "), (2, 1, 'r.sql', " + validTimestamp + ")",
+			},
+			expectedIssues: map[string]int{models.IssueDuplicateMigration: 1},
+			expectedAfter: map[string]int{
+				"SELECT count(*) FROM migration":              1,
+				"SELECT count(*) FROM migration WHERE id = 1": 1,
+			},
+		},
+		{
+			desc: "invalid timestamps are stamped",
+			setup: []string{
+				"INSERT INTO migration_group (id, name) VALUES (1, 'Roles')",
+				"INSERT INTO migration (migration_group_id, name, executed_at) VALUES (1, 's.sql', 'not a date'), (1, 't.sql', '2999-01-01 00:00:00')",
+			},
+			expectedIssues: map[string]int{models.IssueInvalidTimestamp: 2},
+			expectedAfter: map[string]int{
+				"SELECT count(*) FROM migration":                                     2,
+				"SELECT count(*) FROM migration WHERE datetime(executed_at) IS NULL": 0,
+			},
+		},
+		{
+			desc:           "missing tables are created",
+			setup:          []string{"DROP TABLE migration_mark"},
+			expectedIssues: map[string]int{models.IssueMissingTable: 1},
+			expectedAfter:  map[string]int{"SELECT count(*) FROM sqlite_master WHERE name = 'migration_mark'": 1},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			db, err := helpers.GetDb(path.Join(t.TempDir(), "doctor.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if err := repository.EnsureCreated(db); err != nil {
+				t.Fatal(err)
+			}
+			for _, cmd := range tC.setup {
+				if _, err := db.Exec(cmd); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			issues, err := repository.Diagnose(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertIssues(t, tC.expectedIssues, issues)
+
+			repaired, err := repository.Repair(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertIssues(t, tC.expectedIssues, repaired)
+
+			for query, expected := range tC.expectedAfter {
+				var count int
+				if err := db.QueryRow(query).Scan(&count); err != nil {
+					t.Fatal(err)
+				}
+				if count != expected {
+					t.Errorf("%s: expected '%v', got '%v'", query, expected, count)
+				}
+			}
+
+			remaining, err := repository.Diagnose(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) != 0 {
+				t.Errorf("expected '%v', got '%v'", "no issues after repair", remaining)
+			}
+		})
+	}
+}
+
+func assertIssues(t *testing.T, expected map[string]int, issues []models.TrackingIssue) {
+	t.Helper()
+
+	kinds := make(map[string]int)
+	for _, issue := range issues {
+		kinds[issue.Kind]++
+	}
+
+	if len(kinds) != len(expected) {
+		t.Errorf("expected '%v', got '%v'", expected, issues)
+	}
+	for kind, count := range expected {
+		if kinds[kind] != count {
+			t.Errorf("expected '%v' %s issues, got '%v'", count, kind, kinds[kind])
+		}
+	}
+}
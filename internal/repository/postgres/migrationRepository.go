@@ -8,11 +8,13 @@ import (
 	"io"
 	"time"
 
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
 	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/internal/repository"
 	queries "github.com/marianop9/valkyrie-migrate/internal/repository/queries/postgresql"
 )
 
-var ErrInconsistenMigrationSchema = errors.New("found only one of the required tables: migration or migration_group")
+var ErrInconsistenMigrationSchema = errors.New("found only one of the required tables: migration or migration_group. Run 'valkyrie doctor --fix' to repair it")
 
 type MigrationRepo struct {
 	db      *sql.DB
@@ -26,27 +28,103 @@ func NewMigrationRepo(db *sql.DB) *MigrationRepo {
 	}
 }
 
-func (repo *MigrationRepo) EnsureCreated() error {
-	migrationTables := []string{
-		"migration_group",
-		"migration",
+// table definitions match the ones in db/schema/postgresql
+const (
+	migrationGroupTableCmd = `CREATE TABLE IF NOT EXISTS "migration_group" (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL
+	);`
+
+	migrationTableCmd = `CREATE TABLE IF NOT EXISTS migration (
+		id SERIAL,
+		migration_group_id INTEGER NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		executed_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (id),
+		CONSTRAINT fk_migration FOREIGN KEY (migration_group_id) REFERENCES "migration_group" (id)
+	);`
+
+	migrationMarkTableCmd = `CREATE TABLE IF NOT EXISTS migration_mark (
+		id SERIAL PRIMARY KEY,
+		group_name VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		reason TEXT NOT NULL,
+		marked_at TIMESTAMP NOT NULL
+	);`
+
+	repeatableMigrationTableCmd = `CREATE TABLE IF NOT EXISTS repeatable_migration (
+		id SERIAL PRIMARY KEY,
+		group_name VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		executed_at TIMESTAMP NOT NULL
+	);`
+)
+
+// TrackingTablesDDL returns the statements creating the tracking tables, in order.
+func TrackingTablesDDL() []string {
+	return []string{migrationGroupTableCmd, migrationTableCmd, migrationMarkTableCmd, repeatableMigrationTableCmd}
+}
+
+var migrationTables = []string{
+	"migration_group",
+	"migration",
+}
+
+// trackingTables are every table EnsureCreated creates, the migration tables
+// and the ones added after them.
+var trackingTables = []string{
+	"migration_group",
+	"migration",
+	"migration_mark",
+	"repeatable_migration",
+}
+
+func (repo *MigrationRepo) MissingTables() ([]string, error) {
+	query := `SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = current_schema()
+			AND table_name IN ($1, $2, $3, $4);`
+
+	rows, err := repo.db.Query(query, trackingTables[0], trackingTables[1], trackingTables[2], trackingTables[3])
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	query := `SELECT count(1)
-		FROM information_schema.tables 
-		WHERE table_schema = 'public'
-			AND table_name IN ($1, $2);`
+	found := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		found[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	var tableCount int
-	err := repo.db.QueryRow(query, migrationTables[0], migrationTables[1]).Scan(&tableCount)
+	missing := make([]string, 0)
+	for _, table := range trackingTables {
+		if !found[table] {
+			missing = append(missing, table)
+		}
+	}
+
+	return missing, nil
+}
+
+func (repo *MigrationRepo) EnsureCreated() error {
+	foundTables, err := findMigrationTables(repo.db)
 	if err != nil {
 		return err
 	}
 
-	if tableCount == len(migrationTables) {
+	if len(foundTables) == len(migrationTables) {
 		fmt.Println("migrations tables exist")
-		return nil
-	} else if tableCount != 0 {
+		return createAddedTables(repo.db)
+	} else if len(foundTables) != 0 {
 		return ErrInconsistenMigrationSchema
 	}
 
@@ -61,37 +139,148 @@ func (repo *MigrationRepo) EnsureCreated() error {
 		return err
 	}
 
+	if err = createAddedTables(tx); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func createMigrationTables(tx *sql.Tx) error {
-	fmt.Println("creating table 'migration_group'...")
+func findMigrationTables(db repository.Querier) ([]string, error) {
+	query := `SELECT table_name
+		FROM information_schema.tables 
+		WHERE table_schema = current_schema()
+			AND table_name IN ($1, $2);`
 
-	cmd1 := `CREATE TABLE "migration_group" (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL
-	);`
+	rows, err := db.Query(query, migrationTables[0], migrationTables[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	foundTables := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		foundTables = append(foundTables, name)
+	}
+
+	return foundTables, rows.Err()
+}
 
-	if _, err := tx.Exec(cmd1); err != nil {
+func createMigrationTables(tx *sql.Tx) error {
+	if err := createMigrationGroupTable(tx); err != nil {
 		return err
 	}
 
+	return createMigrationTable(tx)
+}
+
+func createMigrationGroupTable(db repository.Querier) error {
+	fmt.Println("creating table 'migration_group'...")
+
+	_, err := db.Exec(migrationGroupTableCmd)
+	return err
+}
+
+func createMigrationTable(db repository.Querier) error {
 	fmt.Println(`creating table 'migration'...`)
 
-	cmd2 := `CREATE TABLE migration (
-		id SERIAL,
-		migration_group_id INTEGER NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		executed_at TIMESTAMP NOT NULL,
-		PRIMARY KEY (id),
-		CONSTRAINT fk_migration FOREIGN KEY (migration_group_id) REFERENCES "migration_group" (id)
-	);`
+	_, err := db.Exec(migrationTableCmd)
+	return err
+}
 
-	if _, err := tx.Exec(cmd2); err != nil {
+// the mark and repeatable tables were added after the migration tables, so
+// they're created separately for databases initialized before they existed.
+func createAddedTables(db repository.Querier) error {
+	if _, err := db.Exec(migrationMarkTableCmd); err != nil {
 		return err
 	}
 
-	return nil
+	_, err := db.Exec(repeatableMigrationTableCmd)
+	return err
+}
+
+const invalidTimestampCond = `(executed_at IS NULL
+	OR executed_at > now() + interval '1 day')`
+
+func (repo *MigrationRepo) Diagnose() ([]models.TrackingIssue, error) {
+	foundTables, err := findMigrationTables(repo.db)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]models.TrackingIssue, 0)
+	for _, table := range migrationTables {
+		if containsTable(foundTables, table) {
+			continue
+		}
+
+		issues = append(issues, models.TrackingIssue{
+			Kind:   models.IssueMissingTable,
+			Detail: fmt.Sprintf("table '%s' doesn't exist", table),
+		})
+	}
+
+	if len(foundTables) != len(migrationTables) {
+		return issues, nil
+	}
+
+	contentIssues, err := repository.DiagnoseTracking(repo.db, invalidTimestampCond)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(issues, contentIssues...), nil
+}
+
+func (repo *MigrationRepo) Repair() ([]models.TrackingIssue, error) {
+	issues, err := repo.Diagnose()
+	if err != nil || len(issues) == 0 {
+		return issues, err
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	foundTables, err := findMigrationTables(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsTable(foundTables, migrationTables[0]) {
+		if err := createMigrationGroupTable(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if !containsTable(foundTables, migrationTables[1]) {
+		if err := createMigrationTable(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := createAddedTables(tx); err != nil {
+		return nil, err
+	}
+
+	if err := repository.RepairTracking(tx, invalidTimestampCond); err != nil {
+		return nil, err
+	}
+
+	return issues, tx.Commit()
+}
+
+func containsTable(tables []string, table string) bool {
+	return helpers.Any(tables, func(t string) bool {
+		return t == table
+	})
 }
 
 func (repo *MigrationRepo) GetMigrations() ([]models.MigrationGroup, error) {
@@ -124,40 +313,95 @@ func (repo *MigrationRepo) getMigrationsByGroup(groupId uint) ([]models.Migratio
 	return migFromQueryList(queryResult), nil
 }
 
-func (repo *MigrationRepo) ExecuteMigrations(migrations []*models.MigrationGroup) error {
-	tx, err := repo.db.Begin()
+func (repo *MigrationRepo) ExecuteMigrations(ctx context.Context, migrations []*models.MigrationGroup, hooks models.ExecutionHooks) error {
+	if hooks == nil {
+		hooks = models.NoHooks{}
+	}
+
+	if pragmas, err := repository.RunPragmas(migrations); err != nil {
+		return err
+	} else if len(pragmas) > 0 {
+		return fmt.Errorf("pragma directives are only supported by SQLite databases")
+	}
+
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	// the timeouts set on the transaction, files change them as they run
+	var timeouts models.Timeouts
+
+	if err := hooks.BeforeRun(tx); err != nil {
+		return err
+	}
+
 	for i := 0; i < len(migrations); i++ {
+		// groups may only have repeatable migrations to run
+		if len(migrations[i].Migrations) == 0 {
+			continue
+		}
+
 		fmt.Printf("executing group %s:\n", migrations[i].Name)
 
-		if err := applyMigration(tx, migrations[i]); err != nil {
-			return fmt.Errorf("failed to execute group '%s', %v", migrations[i].Name, err)
+		if err := hooks.BeforeGroup(tx, migrations[i]); err != nil {
+			return err
+		}
+
+		if err := applyMigration(ctx, tx, migrations[i].Name, migrations[i].Migrations, hooks, &timeouts); err != nil {
+			return fmt.Errorf("failed to execute group '%s', %w", migrations[i].Name, err)
 		}
 
 		if err := logMigration(tx, migrations[i]); err != nil {
-			return fmt.Errorf("failed to log group '%s', %v", migrations[i].Name, err)
+			return fmt.Errorf("failed to log group '%s', %w", migrations[i].Name, err)
+		}
+
+		if err := hooks.AfterGroup(tx, migrations[i]); err != nil {
+			return err
 		}
 
 		fmt.Printf("done executing group %s\n", migrations[i].Name)
 	}
 
+	// repeatable migrations run after every versioned one
+	if err := applyRepeatables(ctx, tx, repo.queries.WithTx(tx), migrations, hooks, &timeouts); err != nil {
+		return err
+	}
+
+	if err := hooks.AfterRun(tx); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func applyMigration(tx *sql.Tx, migration *models.MigrationGroup) error {
-	for _, mig := range migration.Migrations {
+func applyMigration(ctx context.Context, tx *sql.Tx, groupName string, migs []models.Migration, hooks models.ExecutionHooks, timeouts *models.Timeouts) error {
+	for i := range migs {
+		mig := &migs[i]
+
+		if err := hooks.BeforeFile(tx, mig); err != nil {
+			return err
+		}
+
 		buf, err := io.ReadAll(mig.FReader)
 
 		if err != nil {
 			return err
 		}
 
-		if _, sqlErr := tx.Exec(string(buf)); sqlErr != nil {
-			return fmt.Errorf("failed to execute %s: %v", migration.Name, sqlErr)
+		if err := setTimeouts(ctx, tx, mig.Timeouts, timeouts); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		if _, sqlErr := tx.ExecContext(ctx, string(buf)); sqlErr != nil {
+			hooks.FileFailed(mig, sqlErr)
+			return fmt.Errorf("failed to execute %s: %w", groupName, sqlErr)
+		}
+
+		if err := hooks.AfterFile(tx, mig, time.Since(start)); err != nil {
+			return err
 		}
 
 		fmt.Printf("\t * executed %s\n", mig.Name)
@@ -166,6 +410,80 @@ func applyMigration(tx *sql.Tx, migration *models.MigrationGroup) error {
 	return nil
 }
 
+// setTimeouts applies the timeouts of a file to the rest of the transaction
+// when they differ from the current ones. Zero resets them to the defaults.
+func setTimeouts(ctx context.Context, tx *sql.Tx, fileTimeouts models.Timeouts, current *models.Timeouts) error {
+	if fileTimeouts == *current {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, TimeoutsSql(fileTimeouts)); err != nil {
+		return fmt.Errorf("failed to set timeouts: %w", err)
+	}
+
+	*current = fileTimeouts
+	return nil
+}
+
+// TimeoutsSql returns the statements setting the timeouts for the rest of
+// the transaction.
+func TimeoutsSql(timeouts models.Timeouts) string {
+	return fmt.Sprintf("SET LOCAL statement_timeout = %s; SET LOCAL lock_timeout = %s;",
+		timeoutSetting(timeouts.Statement), timeoutSetting(timeouts.Lock))
+}
+
+func timeoutSetting(timeout time.Duration) string {
+	if timeout == 0 {
+		return "DEFAULT"
+	}
+
+	return fmt.Sprintf("%d", timeout.Milliseconds())
+}
+
+func applyRepeatables(ctx context.Context, tx *sql.Tx, txQuery *queries.Queries, groups []*models.MigrationGroup, hooks models.ExecutionHooks, timeouts *models.Timeouts) error {
+	for _, group := range groups {
+		if len(group.Repeatables) == 0 {
+			continue
+		}
+
+		fmt.Printf("executing repeatable migrations of group %s:\n", group.Name)
+
+		if err := applyMigration(ctx, tx, group.Name, group.Repeatables, hooks, timeouts); err != nil {
+			return fmt.Errorf("failed to execute repeatable migrations of group '%s', %w", group.Name, err)
+		}
+
+		// every run is logged, the latest one holds the checksum to compare with
+		logTime := time.Now()
+		for _, mig := range group.Repeatables {
+			err := txQuery.LogRepeatableMigration(context.TODO(), queries.LogRepeatableMigrationParams{
+				GroupName:  group.Name,
+				Name:       mig.Name,
+				Checksum:   mig.Checksum,
+				ExecutedAt: logTime,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to log repeatable migration '%s/%s', %w", group.Name, mig.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (repo *MigrationRepo) GetRepeatableChecksums() (map[string]string, error) {
+	rows, err := repo.queries.GetRepeatableChecksums(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(rows))
+	for _, row := range rows {
+		checksums[models.RepeatableKey(row.GroupName, row.Name)] = row.Checksum
+	}
+
+	return checksums, nil
+}
+
 func logMigration(tx *sql.Tx, group *models.MigrationGroup) error {
 	if group.Id == 0 {
 		// logs are executed manualy because pgx doesn't support returning LastInsertId when
@@ -203,6 +521,178 @@ func logMigration(tx *sql.Tx, group *models.MigrationGroup) error {
 	return nil
 }
 
+func (repo *MigrationRepo) MarkApplied(groupName, migrationName, reason string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := markApplied(tx, repo.queries.WithTx(tx), groupName, migrationName, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *MigrationRepo) MarkAllApplied(marks []models.AppliedMark) ([]models.AppliedMark, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+	logged := make([]models.AppliedMark, 0, len(marks))
+
+	for _, mark := range marks {
+		err := markApplied(tx, txQuery, mark.GroupName, mark.MigrationName, mark.Reason)
+		if errors.Is(err, models.ErrMigrationAlreadyApplied) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		logged = append(logged, mark)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return logged, nil
+}
+
+func markApplied(tx *sql.Tx, txQuery *queries.Queries, groupName, migrationName, reason string) error {
+	group := &models.MigrationGroup{
+		Name: groupName,
+	}
+
+	existingGroup, err := txQuery.GetMigrationGroupByName(context.TODO(), groupName)
+	if err == nil {
+		group.Id = uint(existingGroup.ID)
+
+		migs, err := txQuery.GetMigrationsByGroup(context.TODO(), existingGroup.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migs {
+			if mig.Name == migrationName {
+				return fmt.Errorf("%w: %s/%s", models.ErrMigrationAlreadyApplied, groupName, migrationName)
+			}
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	group.AddMigration(models.Migration{
+		Name:      migrationName,
+		GroupName: groupName,
+	})
+
+	if err := logMigration(tx, group); err != nil {
+		return fmt.Errorf("failed to log migration '%s/%s', %v", groupName, migrationName, err)
+	}
+
+	return logMark(txQuery, groupName, migrationName, models.MarkStatusApplied, reason)
+}
+
+func (repo *MigrationRepo) MarkPending(groupName, migrationName, reason string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+
+	group, err := txQuery.GetMigrationGroupByName(context.TODO(), groupName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s/%s", models.ErrMigrationNotApplied, groupName, migrationName)
+	} else if err != nil {
+		return err
+	}
+
+	deleted, err := txQuery.DeleteMigration(context.TODO(), queries.DeleteMigrationParams{
+		MigrationGroupID: group.ID,
+		Name:             migrationName,
+	})
+	if err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("%w: %s/%s", models.ErrMigrationNotApplied, groupName, migrationName)
+	}
+
+	if err := logMark(txQuery, groupName, migrationName, models.MarkStatusPending, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *MigrationRepo) Squash(groupName string, squashed []string, baseline string, reason string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+
+	existingGroup, err := txQuery.GetMigrationGroupByName(context.TODO(), groupName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: group %s", models.ErrMigrationNotApplied, groupName)
+	} else if err != nil {
+		return err
+	}
+
+	for _, migrationName := range squashed {
+		deleted, err := txQuery.DeleteMigration(context.TODO(), queries.DeleteMigrationParams{
+			MigrationGroupID: existingGroup.ID,
+			Name:             migrationName,
+		})
+		if err != nil {
+			return err
+		} else if deleted == 0 {
+			return fmt.Errorf("%w: %s/%s", models.ErrMigrationNotApplied, groupName, migrationName)
+		}
+
+		if err := logMark(txQuery, groupName, migrationName, models.MarkStatusSquashed, reason); err != nil {
+			return err
+		}
+	}
+
+	group := &models.MigrationGroup{
+		Id:   uint(existingGroup.ID),
+		Name: groupName,
+	}
+	group.AddMigration(models.Migration{
+		Name:      baseline,
+		GroupName: groupName,
+	})
+
+	if err := logMigration(tx, group); err != nil {
+		return fmt.Errorf("failed to log migration '%s/%s', %v", groupName, baseline, err)
+	}
+
+	if err := logMark(txQuery, groupName, baseline, models.MarkStatusApplied, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func logMark(tx *queries.Queries, groupName, migrationName, status, reason string) error {
+	return tx.LogMigrationMark(context.TODO(), queries.LogMigrationMarkParams{
+		GroupName: groupName,
+		Name:      migrationName,
+		Status:    status,
+		Reason:    reason,
+		MarkedAt:  time.Now(),
+	})
+}
+
 func migGroupFromQuery(queryRow *queries.GetMigrationsRow) models.MigrationGroup {
 	return models.MigrationGroup{
 		Id:             uint(queryRow.ID),
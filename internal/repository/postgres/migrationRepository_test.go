@@ -0,0 +1,79 @@
+package postgresRepo_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	postgresRepo "github.com/marianop9/valkyrie-migrate/internal/repository/postgres"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrietest"
+)
+
+func TestMark(t *testing.T) {
+	dbUrl := os.Getenv("VALKYRIE_TEST_POSTGRES_URL")
+	if dbUrl == "" {
+		t.Skip("VALKYRIE_TEST_POSTGRES_URL isn't set")
+	}
+
+	db := valkyrietest.PostgresDatabase(t, dbUrl, fstest.MapFS{})
+	repo := postgresRepo.NewMigrationRepo(db)
+
+	testCases := []struct {
+		desc        string
+		mark        func() error
+		expectedErr error
+	}{
+		{
+			desc:        "creates the group on demand",
+			mark:        func() error { return repo.MarkApplied("Users", "20240101_cr.sql", "test") },
+			expectedErr: nil,
+		},
+		{
+			desc:        "already applied",
+			mark:        func() error { return repo.MarkApplied("Users", "20240101_cr.sql", "test") },
+			expectedErr: models.ErrMigrationAlreadyApplied,
+		},
+		{
+			desc:        "pending",
+			mark:        func() error { return repo.MarkPending("Users", "20240101_cr.sql", "test") },
+			expectedErr: nil,
+		},
+		{
+			desc:        "not applied",
+			mark:        func() error { return repo.MarkPending("Users", "20240101_cr.sql", "test") },
+			expectedErr: models.ErrMigrationNotApplied,
+		},
+		{
+			desc:        "group not applied",
+			mark:        func() error { return repo.MarkPending("Roles", "20240101_cr.sql", "test") },
+			expectedErr: models.ErrMigrationNotApplied,
+		},
+	}
+
+	// the cases run in order, each one on the marks of the previous ones
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if err := tC.mark(); !errors.Is(err, tC.expectedErr) {
+				t.Errorf("expected '%v', got '%v'", tC.expectedErr, err)
+			}
+		})
+	}
+
+	groups, err := repo.GetMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Migrations) != 0 {
+		t.Errorf("expected '%v', got '%v'", "an empty Users group", groups)
+	}
+
+	var marks int
+	if err := db.QueryRow("SELECT count(*) FROM migration_mark WHERE reason = 'test'").Scan(&marks); err != nil {
+		t.Fatal(err)
+	}
+	if marks != 2 {
+		t.Errorf("expected '%v', got '%v'", 2, marks)
+	}
+}
@@ -0,0 +1,94 @@
+package postgresRepo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	queries "github.com/marianop9/valkyrie-migrate/internal/repository/queries/postgresql"
+)
+
+// table definition matches the one in db/schema/postgresql
+const seedTableCmd = `CREATE TABLE IF NOT EXISTS seed_run (
+	id SERIAL PRIMARY KEY,
+	profile VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	executed_at TIMESTAMP NOT NULL,
+	UNIQUE (profile, name)
+);`
+
+func (repo *MigrationRepo) EnsureSeedsCreated() error {
+	_, err := repo.db.Exec(seedTableCmd)
+	return err
+}
+
+func (repo *MigrationRepo) GetSeedChecksums() (map[string]string, error) {
+	rows, err := repo.queries.GetSeedChecksums(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(rows))
+	for _, row := range rows {
+		checksums[models.SeedKey(row.Profile, row.Name)] = row.Checksum
+	}
+
+	return checksums, nil
+}
+
+func (repo *MigrationRepo) ExecuteSeeds(resets []models.Seed, seeds []models.Seed) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+
+	for _, reset := range resets {
+		if reset.FReader != nil {
+			buf, err := io.ReadAll(reset.FReader)
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(string(buf)); err != nil {
+				return fmt.Errorf("failed to reset profile '%s': %v", reset.Profile, err)
+			}
+		}
+
+		if err := txQuery.DeleteSeedsByProfile(context.TODO(), reset.Profile); err != nil {
+			return err
+		}
+
+		fmt.Printf("\t * reset profile %s\n", reset.Profile)
+	}
+
+	for _, seed := range seeds {
+		buf, err := io.ReadAll(seed.FReader)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(string(buf)); err != nil {
+			return fmt.Errorf("failed to execute seed '%s/%s': %v", seed.Profile, seed.Name, err)
+		}
+
+		err = txQuery.LogSeed(context.TODO(), queries.LogSeedParams{
+			Profile:    seed.Profile,
+			Name:       seed.Name,
+			Checksum:   seed.Checksum,
+			ExecutedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to log seed '%s/%s': %v", seed.Profile, seed.Name, err)
+		}
+
+		fmt.Printf("\t * seeded %s/%s\n", seed.Profile, seed.Name)
+	}
+
+	return tx.Commit()
+}
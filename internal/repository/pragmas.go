@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// RunPragmas merges the pragmas toggled by the migrations of a run. They're
+// set for the whole transaction, so migrations can't disagree on them.
+func RunPragmas(groups []*models.MigrationGroup) (map[string]string, error) {
+	pragmas := make(map[string]string)
+	setBy := make(map[string]string)
+
+	for _, group := range groups {
+		for _, migs := range [][]models.Migration{group.Migrations, group.Repeatables} {
+			for _, mig := range migs {
+				for name, value := range mig.Pragmas {
+					ref := group.Name + "/" + mig.Name
+
+					if current, ok := pragmas[name]; ok && current != value {
+						return nil, fmt.Errorf("%s sets pragma %s=%s but %s sets it to %s, apply them in separate runs", ref, name, value, setBy[name], current)
+					}
+
+					pragmas[name] = value
+					setBy[name] = ref
+				}
+			}
+		}
+	}
+
+	return pragmas, nil
+}
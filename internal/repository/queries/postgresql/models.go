@@ -19,3 +19,28 @@ type MigrationGroup struct {
 	ID   int32
 	Name string
 }
+
+type MigrationMark struct {
+	ID        int32
+	GroupName string
+	Name      string
+	Status    string
+	Reason    string
+	MarkedAt  time.Time
+}
+
+type RepeatableMigration struct {
+	ID         int32
+	GroupName  string
+	Name       string
+	Checksum   string
+	ExecutedAt time.Time
+}
+
+type SeedRun struct {
+	ID         int32
+	Profile    string
+	Name       string
+	Checksum   string
+	ExecutedAt time.Time
+}
@@ -11,6 +11,39 @@ import (
 	"time"
 )
 
+const deleteMigration = `-- name: DeleteMigration :execrows
+DELETE FROM migration
+WHERE migration_group_id = $1
+    AND name = $2
+`
+
+type DeleteMigrationParams struct {
+	MigrationGroupID int32
+	Name             string
+}
+
+func (q *Queries) DeleteMigration(ctx context.Context, arg DeleteMigrationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMigration, arg.MigrationGroupID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getMigrationGroupByName = `-- name: GetMigrationGroupByName :one
+SELECT id, name
+FROM migration_group
+WHERE name = $1
+LIMIT 1
+`
+
+func (q *Queries) GetMigrationGroupByName(ctx context.Context, name string) (MigrationGroup, error) {
+	row := q.db.QueryRowContext(ctx, getMigrationGroupByName, name)
+	var i MigrationGroup
+	err := row.Scan(&i.ID, &i.Name)
+	return i, err
+}
+
 const getMigrations = `-- name: GetMigrations :many
 SELECT mg.id,
     mg.name,
@@ -116,3 +149,175 @@ RETURNING id
 func (q *Queries) LogMigrationGroup(ctx context.Context, name string) (sql.Result, error) {
 	return q.db.ExecContext(ctx, logMigrationGroup, name)
 }
+
+const logMigrationMark = `-- name: LogMigrationMark :exec
+INSERT INTO migration_mark (
+    group_name,
+    name,
+    status,
+    reason,
+    marked_at
+) VALUES ($1, $2, $3, $4, $5)
+`
+
+type LogMigrationMarkParams struct {
+	GroupName string
+	Name      string
+	Status    string
+	Reason    string
+	MarkedAt  time.Time
+}
+
+func (q *Queries) LogMigrationMark(ctx context.Context, arg LogMigrationMarkParams) error {
+	_, err := q.db.ExecContext(ctx, logMigrationMark,
+		arg.GroupName,
+		arg.Name,
+		arg.Status,
+		arg.Reason,
+		arg.MarkedAt,
+	)
+	return err
+}
+
+const getRepeatableChecksums = `-- name: GetRepeatableChecksums :many
+SELECT r.group_name,
+    r.name,
+    r.checksum
+FROM repeatable_migration r
+WHERE r.id = (
+    SELECT max(id)
+    FROM repeatable_migration
+    WHERE group_name = r.group_name
+        AND name = r.name
+)
+`
+
+type GetRepeatableChecksumsRow struct {
+	GroupName string
+	Name      string
+	Checksum  string
+}
+
+func (q *Queries) GetRepeatableChecksums(ctx context.Context) ([]GetRepeatableChecksumsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRepeatableChecksums)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRepeatableChecksumsRow
+	for rows.Next() {
+		var i GetRepeatableChecksumsRow
+		if err := rows.Scan(&i.GroupName, &i.Name, &i.Checksum); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const logRepeatableMigration = `-- name: LogRepeatableMigration :exec
+INSERT INTO repeatable_migration (
+    group_name,
+    name,
+    checksum,
+    executed_at
+) VALUES ($1, $2, $3, $4)
+`
+
+type LogRepeatableMigrationParams struct {
+	GroupName  string
+	Name       string
+	Checksum   string
+	ExecutedAt time.Time
+}
+
+func (q *Queries) LogRepeatableMigration(ctx context.Context, arg LogRepeatableMigrationParams) error {
+	_, err := q.db.ExecContext(ctx, logRepeatableMigration,
+		arg.GroupName,
+		arg.Name,
+		arg.Checksum,
+		arg.ExecutedAt,
+	)
+	return err
+}
+
+const deleteSeedsByProfile = `-- name: DeleteSeedsByProfile :exec
+DELETE FROM seed_run
+WHERE profile = $1
+`
+
+func (q *Queries) DeleteSeedsByProfile(ctx context.Context, profile string) error {
+	_, err := q.db.ExecContext(ctx, deleteSeedsByProfile, profile)
+	return err
+}
+
+const getSeedChecksums = `-- name: GetSeedChecksums :many
+SELECT profile,
+    name,
+    checksum
+FROM seed_run
+`
+
+type GetSeedChecksumsRow struct {
+	Profile  string
+	Name     string
+	Checksum string
+}
+
+func (q *Queries) GetSeedChecksums(ctx context.Context) ([]GetSeedChecksumsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSeedChecksums)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSeedChecksumsRow
+	for rows.Next() {
+		var i GetSeedChecksumsRow
+		if err := rows.Scan(&i.Profile, &i.Name, &i.Checksum); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const logSeed = `-- name: LogSeed :exec
+INSERT INTO seed_run (
+    profile,
+    name,
+    checksum,
+    executed_at
+) VALUES ($1, $2, $3, $4)
+ON CONFLICT (profile, name) DO UPDATE
+SET checksum = excluded.checksum,
+    executed_at = excluded.executed_at
+`
+
+type LogSeedParams struct {
+	Profile    string
+	Name       string
+	Checksum   string
+	ExecutedAt time.Time
+}
+
+func (q *Queries) LogSeed(ctx context.Context, arg LogSeedParams) error {
+	_, err := q.db.ExecContext(ctx, logSeed,
+		arg.Profile,
+		arg.Name,
+		arg.Checksum,
+		arg.ExecutedAt,
+	)
+	return err
+}
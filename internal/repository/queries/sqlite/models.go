@@ -19,3 +19,28 @@ type MigrationGroup struct {
 	ID   int64
 	Name string
 }
+
+type MigrationMark struct {
+	ID        int64
+	GroupName string
+	Name      string
+	Status    string
+	Reason    string
+	MarkedAt  time.Time
+}
+
+type RepeatableMigration struct {
+	ID         int64
+	GroupName  string
+	Name       string
+	Checksum   string
+	ExecutedAt time.Time
+}
+
+type SeedRun struct {
+	ID         int64
+	Profile    string
+	Name       string
+	Checksum   string
+	ExecutedAt time.Time
+}
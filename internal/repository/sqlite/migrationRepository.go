@@ -3,8 +3,10 @@ package sqliteRepo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/marianop9/valkyrie-migrate/internal/models"
@@ -28,6 +30,23 @@ func (repo *SqliteRepo) EnsureCreated() error {
 	return repository.EnsureCreated(repo.db)
 }
 
+func (repo *SqliteRepo) MissingTables() ([]string, error) {
+	foundTables, err := repository.FindMigrationTables(repo.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.MissingMigrationTables(foundTables), nil
+}
+
+func (repo *SqliteRepo) Diagnose() ([]models.TrackingIssue, error) {
+	return repository.Diagnose(repo.db)
+}
+
+func (repo *SqliteRepo) Repair() ([]models.TrackingIssue, error) {
+	return repository.Repair(repo.db)
+}
+
 func (repo *SqliteRepo) GetMigrations() ([]models.MigrationGroup, error) {
 	queryRows, err := repo.queries.GetMigrations(context.TODO())
 	if err != nil {
@@ -58,42 +77,193 @@ func (repo *SqliteRepo) getMigrationsByGroup(groupId uint) ([]models.Migration,
 	return migFromQueryList(queryResult), nil
 }
 
-func (repo *SqliteRepo) ExecuteMigrations(migrations []*models.MigrationGroup) error {
-	tx, err := repo.db.Begin()
+func (repo *SqliteRepo) ExecuteMigrations(ctx context.Context, migrations []*models.MigrationGroup, hooks models.ExecutionHooks) (err error) {
+	if hooks == nil {
+		hooks = models.NoHooks{}
+	}
+
+	pragmas, err := repository.RunPragmas(migrations)
+	if err != nil {
+		return err
+	}
+
+	// pragmas are no-ops inside a transaction, so they're set on the
+	// connection running it and restored once it ends
+	conn, err := repo.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	previous, err := setPragmas(ctx, conn, pragmas)
+	defer func() {
+		err = errors.Join(err, restorePragmas(conn, previous))
+	}()
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if err := hooks.BeforeRun(tx); err != nil {
+		return err
+	}
+
 	txQuery := repo.queries.WithTx(tx)
 	
 	for i := 0; i < len(migrations); i++ {
+		// groups may only have repeatable migrations to run
+		if len(migrations[i].Migrations) == 0 {
+			continue
+		}
+
 		fmt.Printf("executing group %s:\n", migrations[i].Name)
 
-		if err := applyMigration(tx, migrations[i]); err != nil {
-			return fmt.Errorf("failed to execute group '%s', %v", migrations[i].Name, err)
+		if err := hooks.BeforeGroup(tx, migrations[i]); err != nil {
+			return err
+		}
+
+		if err := applyMigration(ctx, tx, migrations[i].Name, migrations[i].Migrations, hooks); err != nil {
+			return fmt.Errorf("failed to execute group '%s', %w", migrations[i].Name, err)
 		}
 
 		if err := logMigration(txQuery, migrations[i]); err != nil {
-			return fmt.Errorf("failed to log group '%s', %v", migrations[i].Name, err)
+			return fmt.Errorf("failed to log group '%s', %w", migrations[i].Name, err)
+		}
+
+		if err := hooks.AfterGroup(tx, migrations[i]); err != nil {
+			return err
 		}
 
 		fmt.Printf("done executing group %s\n", migrations[i].Name)
 	}
 
+	// repeatable migrations run after every versioned one
+	if err := applyRepeatables(ctx, tx, txQuery, migrations, hooks); err != nil {
+		return err
+	}
+
+	if err := hooks.AfterRun(tx); err != nil {
+		return err
+	}
+
+	// foreign keys switched off aren't checked by the commit
+	if previous["foreign_keys"] == "ON" {
+		if err := checkForeignKeys(ctx, tx); err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
-func applyMigration(tx *sql.Tx, migration *models.MigrationGroup) error {
-	for _, mig := range migration.Migrations {
+// setPragmas sets the pragmas on the connection, returning the previous
+// values of the ones it changed.
+func setPragmas(ctx context.Context, conn *sql.Conn, pragmas map[string]string) (map[string]string, error) {
+	previous := make(map[string]string)
+
+	for name, value := range pragmas {
+		var current int
+		if err := conn.QueryRowContext(ctx, "PRAGMA "+name).Scan(&current); err != nil {
+			return previous, fmt.Errorf("failed to read pragma %s: %w", name, err)
+		}
+
+		currentValue := "OFF"
+		if current != 0 {
+			currentValue = "ON"
+		}
+		if currentValue == value {
+			continue
+		}
+
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA %s = %s", name, value)); err != nil {
+			return previous, fmt.Errorf("failed to set pragma %s: %w", name, err)
+		}
+		previous[name] = currentValue
+	}
+
+	return previous, nil
+}
+
+// restorePragmas sets the pragmas back, so the connection goes back to the
+// pool as it was.
+func restorePragmas(conn *sql.Conn, previous map[string]string) error {
+	for name, value := range previous {
+		if _, err := conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA %s = %s", name, value)); err != nil {
+			return fmt.Errorf("failed to restore pragma %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkForeignKeys fails when rows reference missing parents.
+func checkForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	violations := make([]string, 0)
+	for rows.Next() {
+		var table, parent string
+		var rowId sql.NullInt64
+		var fkId int
+		if err := rows.Scan(&table, &rowId, &parent, &fkId); err != nil {
+			return err
+		}
+
+		violations = append(violations, fmt.Sprintf("%s row %v references a missing %s", table, rowId.Int64, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("found %v foreign key violation(s) with foreign_keys off: %s", len(violations), strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, tx *sql.Tx, groupName string, migs []models.Migration, hooks models.ExecutionHooks) error {
+	for i := range migs {
+		mig := &migs[i]
+
+		if err := hooks.BeforeFile(tx, mig); err != nil {
+			return err
+		}
+
 		buf, err := io.ReadAll(mig.FReader)
 
 		if err != nil {
 			return err
 		}
 
-		if _, sqlErr := tx.Exec(string(buf)); sqlErr != nil {
-			return fmt.Errorf("failed to execute %s: %v", migration.Name, sqlErr)
+		fileCtx, done, err := fileContext(ctx, tx, mig.Timeouts)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		_, sqlErr := tx.ExecContext(fileCtx, string(buf))
+		elapsed := time.Since(start)
+		doneErr := done()
+		if sqlErr != nil {
+			hooks.FileFailed(mig, sqlErr)
+			return errors.Join(fmt.Errorf("failed to execute %s: %w", groupName, sqlErr), doneErr)
+		}
+		if doneErr != nil {
+			return doneErr
+		}
+
+		if err := hooks.AfterFile(tx, mig, elapsed); err != nil {
+			return err
 		}
 
 		fmt.Printf("\t * executed %s\n", mig.Name)
@@ -102,6 +272,89 @@ func applyMigration(tx *sql.Tx, migration *models.MigrationGroup) error {
 	return nil
 }
 
+// fileContext applies the timeouts of a file: SQLite has no lock timeout, so
+// the lock timeout is the busy timeout of the connection, and the statement
+// timeout is a deadline on the file's statements. done ends the deadline and
+// sets the busy timeout of the connection back, files without a lock timeout
+// keep the one of the connection string.
+func fileContext(ctx context.Context, tx *sql.Tx, fileTimeouts models.Timeouts) (fileCtx context.Context, done func() error, err error) {
+	restore := func() error { return nil }
+
+	if fileTimeouts.Lock > 0 {
+		var previous int64
+		if err := tx.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&previous); err != nil {
+			return nil, nil, fmt.Errorf("failed to read busy timeout: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", fileTimeouts.Lock.Milliseconds())); err != nil {
+			return nil, nil, fmt.Errorf("failed to set busy timeout: %w", err)
+		}
+
+		restore = func() error {
+			if _, err := tx.ExecContext(context.Background(), fmt.Sprintf("PRAGMA busy_timeout = %d", previous)); err != nil {
+				return fmt.Errorf("failed to restore busy timeout: %w", err)
+			}
+			return nil
+		}
+	}
+
+	var cancel context.CancelFunc
+	if fileTimeouts.Statement > 0 {
+		fileCtx, cancel = context.WithTimeout(ctx, fileTimeouts.Statement)
+	} else {
+		fileCtx, cancel = context.WithCancel(ctx)
+	}
+
+	return fileCtx, func() error {
+		cancel()
+		return restore()
+	}, nil
+}
+
+func applyRepeatables(ctx context.Context, tx *sql.Tx, txQuery *queries.Queries, groups []*models.MigrationGroup, hooks models.ExecutionHooks) error {
+	for _, group := range groups {
+		if len(group.Repeatables) == 0 {
+			continue
+		}
+
+		fmt.Printf("executing repeatable migrations of group %s:\n", group.Name)
+
+		if err := applyMigration(ctx, tx, group.Name, group.Repeatables, hooks); err != nil {
+			return fmt.Errorf("failed to execute repeatable migrations of group '%s', %w", group.Name, err)
+		}
+
+		// every run is logged, the latest one holds the checksum to compare with
+		logTime := time.Now()
+		for _, mig := range group.Repeatables {
+			err := txQuery.LogRepeatableMigration(context.TODO(), queries.LogRepeatableMigrationParams{
+				GroupName:  group.Name,
+				Name:       mig.Name,
+				Checksum:   mig.Checksum,
+				ExecutedAt: logTime,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to log repeatable migration '%s/%s', %w", group.Name, mig.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (repo *SqliteRepo) GetRepeatableChecksums() (map[string]string, error) {
+	rows, err := repo.queries.GetRepeatableChecksums(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(rows))
+	for _, row := range rows {
+		checksums[models.RepeatableKey(row.GroupName, row.Name)] = row.Checksum
+	}
+
+	return checksums, nil
+}
+
 func logMigration(tx *queries.Queries, group *models.MigrationGroup) error {
 
 	if group.Id == 0 {
@@ -133,6 +386,178 @@ func logMigration(tx *queries.Queries, group *models.MigrationGroup) error {
 	return nil
 }
 
+func (repo *SqliteRepo) MarkApplied(groupName, migrationName, reason string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := markApplied(repo.queries.WithTx(tx), groupName, migrationName, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *SqliteRepo) MarkAllApplied(marks []models.AppliedMark) ([]models.AppliedMark, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+	logged := make([]models.AppliedMark, 0, len(marks))
+
+	for _, mark := range marks {
+		err := markApplied(txQuery, mark.GroupName, mark.MigrationName, mark.Reason)
+		if errors.Is(err, models.ErrMigrationAlreadyApplied) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		logged = append(logged, mark)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return logged, nil
+}
+
+func markApplied(txQuery *queries.Queries, groupName, migrationName, reason string) error {
+	group := &models.MigrationGroup{
+		Name: groupName,
+	}
+
+	existingGroup, err := txQuery.GetMigrationGroupByName(context.TODO(), groupName)
+	if err == nil {
+		group.Id = uint(existingGroup.ID)
+
+		migs, err := txQuery.GetMigrationsByGroup(context.TODO(), existingGroup.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migs {
+			if mig.Name == migrationName {
+				return fmt.Errorf("%w: %s/%s", models.ErrMigrationAlreadyApplied, groupName, migrationName)
+			}
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	group.AddMigration(models.Migration{
+		Name:      migrationName,
+		GroupName: groupName,
+	})
+
+	if err := logMigration(txQuery, group); err != nil {
+		return fmt.Errorf("failed to log migration '%s/%s', %v", groupName, migrationName, err)
+	}
+
+	return logMark(txQuery, groupName, migrationName, models.MarkStatusApplied, reason)
+}
+
+func (repo *SqliteRepo) MarkPending(groupName, migrationName, reason string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+
+	group, err := txQuery.GetMigrationGroupByName(context.TODO(), groupName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s/%s", models.ErrMigrationNotApplied, groupName, migrationName)
+	} else if err != nil {
+		return err
+	}
+
+	deleted, err := txQuery.DeleteMigration(context.TODO(), queries.DeleteMigrationParams{
+		GroupId: group.ID,
+		Name:    migrationName,
+	})
+	if err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("%w: %s/%s", models.ErrMigrationNotApplied, groupName, migrationName)
+	}
+
+	if err := logMark(txQuery, groupName, migrationName, models.MarkStatusPending, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *SqliteRepo) Squash(groupName string, squashed []string, baseline string, reason string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txQuery := repo.queries.WithTx(tx)
+
+	existingGroup, err := txQuery.GetMigrationGroupByName(context.TODO(), groupName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: group %s", models.ErrMigrationNotApplied, groupName)
+	} else if err != nil {
+		return err
+	}
+
+	for _, migrationName := range squashed {
+		deleted, err := txQuery.DeleteMigration(context.TODO(), queries.DeleteMigrationParams{
+			GroupId: existingGroup.ID,
+			Name:    migrationName,
+		})
+		if err != nil {
+			return err
+		} else if deleted == 0 {
+			return fmt.Errorf("%w: %s/%s", models.ErrMigrationNotApplied, groupName, migrationName)
+		}
+
+		if err := logMark(txQuery, groupName, migrationName, models.MarkStatusSquashed, reason); err != nil {
+			return err
+		}
+	}
+
+	group := &models.MigrationGroup{
+		Id:   uint(existingGroup.ID),
+		Name: groupName,
+	}
+	group.AddMigration(models.Migration{
+		Name:      baseline,
+		GroupName: groupName,
+	})
+
+	if err := logMigration(txQuery, group); err != nil {
+		return fmt.Errorf("failed to log migration '%s/%s', %v", groupName, baseline, err)
+	}
+
+	if err := logMark(txQuery, groupName, baseline, models.MarkStatusApplied, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func logMark(tx *queries.Queries, groupName, migrationName, status, reason string) error {
+	return tx.LogMigrationMark(context.TODO(), queries.LogMigrationMarkParams{
+		GroupName: groupName,
+		Name:      migrationName,
+		Status:    status,
+		Reason:    reason,
+		MarkedAt:  time.Now(),
+	})
+}
+
 func migGroupFromQuery(queryRow *queries.GetMigrationsRow) models.MigrationGroup {
 	return models.MigrationGroup{
 		Id:             uint(queryRow.ID),
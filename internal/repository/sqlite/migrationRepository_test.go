@@ -37,4 +37,220 @@ package sqliteRepo_test
 
 // 	return db
 
-// }
\ No newline at end of file
+// }
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	sqliteRepo "github.com/marianop9/valkyrie-migrate/internal/repository/sqlite"
+)
+
+func newTestRepo(t *testing.T) (*sqliteRepo.SqliteRepo, func(query string) int) {
+	db, err := helpers.GetDb(path.Join(t.TempDir(), "repo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := sqliteRepo.NewMigrationRepo(db)
+	if err := repo.EnsureCreated(); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	return repo, count
+}
+
+func TestMark(t *testing.T) {
+	type mark struct {
+		applied   bool
+		group     string
+		migration string
+	}
+
+	testCases := []struct {
+		desc           string
+		marks          []mark
+		expectedErr    error
+		expectedGroups map[string]int
+		expectedMarks  int
+	}{
+		{
+			desc:           "creates the group on demand",
+			marks:          []mark{{true, "Users", "20240101_cr.sql"}},
+			expectedGroups: map[string]int{"Users": 1},
+			expectedMarks:  1,
+		},
+		{
+			desc:           "reuses the group",
+			marks:          []mark{{true, "Users", "20240101_cr.sql"}, {true, "Users", "20240102_alter.sql"}},
+			expectedGroups: map[string]int{"Users": 2},
+			expectedMarks:  2,
+		},
+		{
+			desc:           "already applied",
+			marks:          []mark{{true, "Users", "20240101_cr.sql"}, {true, "Users", "20240101_cr.sql"}},
+			expectedErr:    models.ErrMigrationAlreadyApplied,
+			expectedGroups: map[string]int{"Users": 1},
+			expectedMarks:  1,
+		},
+		{
+			desc:           "pending without group",
+			marks:          []mark{{false, "Users", "20240101_cr.sql"}},
+			expectedErr:    models.ErrMigrationNotApplied,
+			expectedGroups: map[string]int{},
+		},
+		{
+			desc:           "pending without migration",
+			marks:          []mark{{true, "Users", "20240101_cr.sql"}, {false, "Users", "20240102_alter.sql"}},
+			expectedErr:    models.ErrMigrationNotApplied,
+			expectedGroups: map[string]int{"Users": 1},
+			expectedMarks:  1,
+		},
+		{
+			desc: "pending leaves empty groups",
+			marks: []mark{
+				{true, "Users", "20240101_cr.sql"},
+				{true, "Roles", "20240101_cr.sql"},
+				{false, "Users", "20240101_cr.sql"},
+				{false, "Roles", "20240101_cr.sql"},
+			},
+			expectedGroups: map[string]int{"Users": 0, "Roles": 0},
+			expectedMarks:  4,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			repo, count := newTestRepo(t)
+
+			var err error
+			for _, m := range tC.marks {
+				if m.applied {
+					err = repo.MarkApplied(m.group, m.migration, "test")
+				} else {
+					err = repo.MarkPending(m.group, m.migration, "test")
+				}
+				if err != nil {
+					break
+				}
+			}
+
+			if !errors.Is(err, tC.expectedErr) {
+				t.Errorf("expected '%v', got '%v'", tC.expectedErr, err)
+			}
+
+			groups, err := repo.GetMigrations()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(groups) != len(tC.expectedGroups) {
+				t.Errorf("expected '%v' groups, got '%v'", len(tC.expectedGroups), len(groups))
+			}
+			for _, group := range groups {
+				if expected, ok := tC.expectedGroups[group.Name]; !ok || len(group.Migrations) != expected {
+					t.Errorf("expected '%v' migrations in %s, got '%v'", expected, group.Name, len(group.Migrations))
+				}
+			}
+
+			if marks := count("SELECT count(*) FROM migration_mark WHERE reason = 'test'"); marks != tC.expectedMarks {
+				t.Errorf("expected '%v', got '%v'", tC.expectedMarks, marks)
+			}
+		})
+	}
+}
+
+func TestMarkAuditRow(t *testing.T) {
+	repo, count := newTestRepo(t)
+
+	if err := repo.MarkApplied("Users", "20240101_cr.sql", "applied by hand"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkPending("Users", "20240101_cr.sql", "reverted by hand"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc  string
+		query string
+	}{
+		{
+			desc:  "applied",
+			query: "SELECT count(*) FROM migration_mark WHERE group_name = 'Users' AND name = '20240101_cr.sql' AND status = 'applied' AND reason = 'applied by hand'",
+		},
+		{
+			desc:  "pending",
+			query: "SELECT count(*) FROM migration_mark WHERE group_name = 'Users' AND name = '20240101_cr.sql' AND status = 'pending' AND reason = 'reverted by hand'",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if marks := count(tC.query); marks != 1 {
+				t.Errorf("expected '%v', got '%v'", 1, marks)
+			}
+		})
+	}
+}
+
+func TestMarkAllApplied(t *testing.T) {
+	repo, count := newTestRepo(t)
+
+	if err := repo.MarkApplied("Users", "20240101_cr.sql", "applied by hand"); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := repo.MarkAllApplied([]models.AppliedMark{
+		{GroupName: "Users", MigrationName: "20240101_cr.sql", Reason: "imported"},
+		{GroupName: "Users", MigrationName: "20240102_alter.sql", Reason: "imported"},
+		{GroupName: "Orders", MigrationName: "20240101_cr.sql", Reason: "imported"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(logged) != 2 {
+		t.Errorf("expected '%v', got '%v'", 2, len(logged))
+	}
+
+	testCases := []struct {
+		desc     string
+		query    string
+		expected int
+	}{
+		{
+			desc:     "migrations",
+			query:    "SELECT count(*) FROM migration",
+			expected: 3,
+		},
+		{
+			desc:     "groups",
+			query:    "SELECT count(*) FROM migration_group",
+			expected: 2,
+		},
+		{
+			desc:     "imported marks",
+			query:    "SELECT count(*) FROM migration_mark WHERE status = 'applied' AND reason = 'imported'",
+			expected: 2,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if rows := count(tC.query); rows != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, rows)
+			}
+		})
+	}
+}
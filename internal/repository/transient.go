@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// transientSqlStates are the Postgres error codes of failures that may not
+// happen again when the transaction is retried.
+var transientSqlStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available, raised by lock_timeout
+	"57P01": true, // admin_shutdown, raised on failovers
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// IsTransient reports whether err is a failure that retrying the transaction
+// may fix: a lost or refused connection, a serialization failure, a deadlock
+// or a lock that couldn't be acquired in time. A connection lost while
+// committing may have committed, so retries must check what's applied.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// class 08 are connection exceptions
+		return transientSqlStates[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	// a connection that couldn't be opened did nothing, one lost later
+	// is only retried through the errors below
+	var netErr *net.OpError
+	if errors.As(err, &netErr) && netErr.Op == "dial" {
+		return true
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
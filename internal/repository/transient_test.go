@@ -0,0 +1,39 @@
+package repository_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestIsTransient(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{desc: "no error", err: nil},
+		{desc: "busy database", err: sqlite3.Error{Code: sqlite3.ErrBusy}, expected: true},
+		{desc: "constraint violation", err: sqlite3.Error{Code: sqlite3.ErrConstraint}},
+		{desc: "failed dial", err: &net.OpError{Op: "dial", Err: errors.New("no route to host")}, expected: true},
+		{desc: "refused connection", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, expected: true},
+		{desc: "read timeout", err: &net.OpError{Op: "read", Err: errors.New("i/o timeout")}},
+		{desc: "reset connection", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, expected: true},
+		{desc: "wrapped lost connection", err: fmt.Errorf("failed to commit: %w", io.ErrUnexpectedEOF), expected: true},
+		{desc: "other error", err: errors.New("syntax error")},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if got := repository.IsTransient(tC.err); got != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, got)
+			}
+		})
+	}
+}
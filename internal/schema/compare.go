@@ -0,0 +1,130 @@
+package schema
+
+import "fmt"
+
+// Difference is a single mismatch between an expected and an actual schema.
+type Difference struct {
+	Kind   string
+	Object string
+	Detail string
+}
+
+func (d Difference) String() string {
+	if d.Detail == "" {
+		return fmt.Sprintf("%s: %s", d.Kind, d.Object)
+	}
+
+	return fmt.Sprintf("%s: %s (%s)", d.Kind, d.Object, d.Detail)
+}
+
+// Compare reports every difference between the expected and actual schemas.
+// Objects only found in expected are reported as missing, objects only found
+// in actual as extra.
+func Compare(expected *Schema, actual *Schema) []Difference {
+	diffs := make([]Difference, 0)
+
+	for _, expectedTable := range expected.Tables {
+		actualTable := actual.FindTable(expectedTable.Name)
+		if actualTable == nil {
+			diffs = append(diffs, Difference{Kind: "missing table", Object: expectedTable.Name})
+			continue
+		}
+
+		diffs = append(diffs, compareTables(&expectedTable, actualTable)...)
+	}
+
+	for _, actualTable := range actual.Tables {
+		if expected.FindTable(actualTable.Name) == nil {
+			diffs = append(diffs, Difference{Kind: "extra table", Object: actualTable.Name})
+		}
+	}
+
+	diffs = append(diffs, compareDefinitions("view", "", expected.Views, actual.Views)...)
+	diffs = append(diffs, compareDefinitions("function", "", expected.Functions, actual.Functions)...)
+
+	return diffs
+}
+
+func compareTables(expected *Table, actual *Table) []Difference {
+	diffs := make([]Difference, 0)
+
+	for _, expectedCol := range expected.Columns {
+		object := expected.Name + "." + expectedCol.Name
+
+		actualCol := actual.FindColumn(expectedCol.Name)
+		if actualCol == nil {
+			diffs = append(diffs, Difference{Kind: "missing column", Object: object})
+			continue
+		}
+
+		if expectedCol.Type != actualCol.Type {
+			diffs = append(diffs, Difference{
+				Kind:   "type change",
+				Object: object,
+				Detail: fmt.Sprintf("expected %s, found %s", expectedCol.Type, actualCol.Type),
+			})
+		}
+
+		if expectedCol.NotNull != actualCol.NotNull {
+			diffs = append(diffs, Difference{
+				Kind:   "nullability change",
+				Object: object,
+				Detail: fmt.Sprintf("expected not null: %v, found %v", expectedCol.NotNull, actualCol.NotNull),
+			})
+		}
+
+		if expectedCol.Default != actualCol.Default {
+			diffs = append(diffs, Difference{
+				Kind:   "default change",
+				Object: object,
+				Detail: fmt.Sprintf("expected '%s', found '%s'", expectedCol.Default, actualCol.Default),
+			})
+		}
+	}
+
+	for _, actualCol := range actual.Columns {
+		if expected.FindColumn(actualCol.Name) == nil {
+			diffs = append(diffs, Difference{Kind: "extra column", Object: expected.Name + "." + actualCol.Name})
+		}
+	}
+
+	diffs = append(diffs, compareDefinitions("constraint", expected.Name+".", expected.Constraints, actual.Constraints)...)
+	diffs = append(diffs, compareDefinitions("index", expected.Name+".", expected.Indexes, actual.Indexes)...)
+
+	return diffs
+}
+
+func compareDefinitions(kind string, prefix string, expected []Definition, actual []Definition) []Difference {
+	diffs := make([]Difference, 0)
+
+	for _, expectedDef := range expected {
+		actualDef := findDefinition(actual, expectedDef.Name)
+		if actualDef == nil {
+			diffs = append(diffs, Difference{Kind: "missing " + kind, Object: prefix + expectedDef.Name})
+		} else if actualDef.Sql != expectedDef.Sql {
+			diffs = append(diffs, Difference{
+				Kind:   "changed " + kind,
+				Object: prefix + expectedDef.Name,
+				Detail: fmt.Sprintf("expected '%s', found '%s'", expectedDef.Sql, actualDef.Sql),
+			})
+		}
+	}
+
+	for _, actualDef := range actual {
+		if findDefinition(expected, actualDef.Name) == nil {
+			diffs = append(diffs, Difference{Kind: "extra " + kind, Object: prefix + actualDef.Name})
+		}
+	}
+
+	return diffs
+}
+
+func findDefinition(defs []Definition, name string) *Definition {
+	for i, def := range defs {
+		if def.Name == name {
+			return &defs[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// rebuildPrefix names the temporary table sqlite tables are rebuilt into.
+const rebuildPrefix = "_valkyrie_new_"
+
+// foreignKeysOffHeader is the header directive running a migration with
+// sqlite's foreign keys off.
+const foreignKeysOffHeader = "-- valkyrie:pragma foreign_keys=OFF"
+
+// MigrationHeader returns the header directives the migration written from
+// the statements of MigrationSql needs, to be placed at the top of its file.
+//
+// Rebuilding a sqlite table drops it, which cascades deletes and nulls to the
+// rows referencing it while foreign keys are on, even deferred. Migrations
+// rebuilding tables run with them off, and the run checks the foreign keys
+// before committing.
+func MigrationHeader(from *Schema, to *Schema, driver string) []string {
+	if len(rebuiltTables(from, to, driver)) > 0 {
+		return []string{foreignKeysOffHeader}
+	}
+
+	return []string{}
+}
+
+// rebuiltTables returns the tables sqlite can't migrate with ALTER TABLE.
+func rebuiltTables(from *Schema, to *Schema, driver string) map[string]bool {
+	rebuilt := make(map[string]bool)
+	if driver != "sqlite" {
+		return rebuilt
+	}
+
+	for _, toTable := range to.Tables {
+		if fromTable := from.FindTable(toTable.Name); fromTable != nil && needsRebuild(fromTable, &toTable) {
+			rebuilt[toTable.Name] = true
+		}
+	}
+
+	return rebuilt
+}
+
+// MigrationSql returns the ddl statements that turn the from schema into the
+// to schema for the given driver. Tables, columns, constraints, indexes and
+// views are migrated; function changes are only reported as comments.
+//
+// Sqlite can't alter columns or constraints, so those tables are rebuilt:
+// created under a temporary name, filled from the old table, and renamed.
+// They must run with the directives of MigrationHeader.
+func MigrationSql(from *Schema, to *Schema, driver string) []string {
+	stmts := make([]string, 0)
+
+	rebuilt := rebuiltTables(from, to, driver)
+
+	// views may depend on any table, drop them before touching tables
+	for _, view := range from.Views {
+		if toView := findDefinition(to.Views, view.Name); toView == nil || toView.Sql != view.Sql {
+			stmts = append(stmts, "DROP VIEW "+quoteIdent(view.Name))
+		}
+	}
+
+	// drop what changed or no longer exists on the tables being kept
+	for _, fromTable := range from.Tables {
+		toTable := to.FindTable(fromTable.Name)
+		if toTable == nil || rebuilt[fromTable.Name] {
+			continue
+		}
+
+		for _, idx := range fromTable.Indexes {
+			if toIdx := findDefinition(toTable.Indexes, idx.Name); toIdx == nil || toIdx.Sql != idx.Sql {
+				stmts = append(stmts, dropIndexSql(idx))
+			}
+		}
+
+		if driver != "sqlite" {
+			for _, con := range fromTable.Constraints {
+				if toCon := findDefinition(toTable.Constraints, con.Name); toCon == nil || toCon.Sql != con.Sql {
+					stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", quoteIdent(fromTable.Name), quoteIdent(con.Name)))
+				}
+			}
+		}
+	}
+
+	newTables := make([]Table, 0)
+	for _, toTable := range to.Tables {
+		if from.FindTable(toTable.Name) == nil {
+			newTables = append(newTables, toTable)
+		}
+	}
+	for _, table := range sortByDependencies(newTables) {
+		stmts = append(stmts, tableSql(&table))
+		stmts = append(stmts, createIndexesSql(table.Indexes)...)
+	}
+
+	for _, toTable := range to.Tables {
+		fromTable := from.FindTable(toTable.Name)
+		if fromTable == nil {
+			continue
+		}
+
+		if rebuilt[toTable.Name] {
+			stmts = append(stmts, rebuildTableSql(fromTable, &toTable)...)
+			continue
+		}
+
+		stmts = append(stmts, alterColumnsSql(fromTable, &toTable, driver)...)
+
+		if driver != "sqlite" {
+			for _, con := range toTable.Constraints {
+				if fromCon := findDefinition(fromTable.Constraints, con.Name); fromCon == nil || fromCon.Sql != con.Sql {
+					stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", quoteIdent(toTable.Name), quoteIdent(con.Name), con.Sql))
+				}
+			}
+		}
+
+		for _, idx := range toTable.Indexes {
+			if fromIdx := findDefinition(fromTable.Indexes, idx.Name); fromIdx == nil || fromIdx.Sql != idx.Sql {
+				stmts = append(stmts, idx.Sql)
+			}
+		}
+	}
+
+	oldTables := make([]Table, 0)
+	for _, fromTable := range from.Tables {
+		if to.FindTable(fromTable.Name) == nil {
+			oldTables = append(oldTables, fromTable)
+		}
+	}
+	// drop referencing tables first
+	oldTables = sortByDependencies(oldTables)
+	for i := len(oldTables) - 1; i >= 0; i-- {
+		stmts = append(stmts, "DROP TABLE "+quoteIdent(oldTables[i].Name))
+	}
+
+	for _, view := range to.Views {
+		if fromView := findDefinition(from.Views, view.Name); fromView == nil || fromView.Sql != view.Sql {
+			stmts = append(stmts, view.Sql)
+		}
+	}
+
+	for _, diff := range compareDefinitions("function", "", from.Functions, to.Functions) {
+		stmts = append(stmts, fmt.Sprintf("-- %s: %s, not migrated", diff.Kind, diff.Object))
+	}
+
+	return stmts
+}
+
+// needsRebuild reports whether sqlite can't migrate the table with ALTER TABLE.
+func needsRebuild(from *Table, to *Table) bool {
+	for _, toCol := range to.Columns {
+		fromCol := from.FindColumn(toCol.Name)
+		if fromCol == nil {
+			// added columns can't be NOT NULL without a default
+			if toCol.NotNull && toCol.Default == "" {
+				return true
+			}
+			continue
+		}
+
+		if *fromCol != toCol {
+			return true
+		}
+	}
+
+	for _, fromCol := range from.Columns {
+		if to.FindColumn(fromCol.Name) == nil {
+			return true
+		}
+	}
+
+	if len(from.Constraints) != len(to.Constraints) {
+		return true
+	}
+	for _, con := range to.Constraints {
+		if fromCon := findDefinition(from.Constraints, con.Name); fromCon == nil || fromCon.Sql != con.Sql {
+			return true
+		}
+	}
+
+	return false
+}
+
+func alterColumnsSql(from *Table, to *Table, driver string) []string {
+	stmts := make([]string, 0)
+	tableName := quoteIdent(to.Name)
+
+	for _, toCol := range to.Columns {
+		fromCol := from.FindColumn(toCol.Name)
+		if fromCol == nil {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, toCol))
+			continue
+		}
+
+		// sqlite tables with changed columns are rebuilt instead
+		if driver == "sqlite" {
+			continue
+		}
+
+		alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", tableName, quoteIdent(toCol.Name))
+
+		if fromCol.Type != toCol.Type {
+			stmts = append(stmts, alter+fmt.Sprintf("TYPE %s USING %s::%s", toCol.Type, quoteIdent(toCol.Name), toCol.Type))
+		}
+
+		if fromCol.Default != toCol.Default {
+			if toCol.Default == "" {
+				stmts = append(stmts, alter+"DROP DEFAULT")
+			} else {
+				stmts = append(stmts, alter+"SET DEFAULT "+toCol.Default)
+			}
+		}
+
+		if fromCol.NotNull != toCol.NotNull {
+			if toCol.NotNull {
+				stmts = append(stmts, alter+"SET NOT NULL")
+			} else {
+				stmts = append(stmts, alter+"DROP NOT NULL")
+			}
+		}
+	}
+
+	for _, fromCol := range from.Columns {
+		if to.FindColumn(fromCol.Name) == nil {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, quoteIdent(fromCol.Name)))
+		}
+	}
+
+	return stmts
+}
+
+func rebuildTableSql(from *Table, to *Table) []string {
+	tmpName := rebuildPrefix + to.Name
+
+	// copy the columns both versions have
+	cols := make([]string, 0)
+	for _, col := range to.Columns {
+		if from.FindColumn(col.Name) != nil {
+			cols = append(cols, quoteIdent(col.Name))
+		}
+	}
+
+	// the ddl the table was created with keeps what its columns can't
+	// describe, like CHECK constraints, collations and AUTOINCREMENT
+	createSql, ok := renameTableSql(to.Sql, tmpName)
+	if !ok {
+		createSql = createTableSql(to, tmpName)
+	}
+
+	stmts := []string{createSql}
+	if len(cols) > 0 {
+		colList := strings.Join(cols, ", ")
+		stmts = append(stmts, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quoteIdent(tmpName), colList, colList, quoteIdent(to.Name)))
+	}
+	stmts = append(stmts,
+		"DROP TABLE "+quoteIdent(to.Name),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(tmpName), quoteIdent(to.Name)),
+	)
+
+	// indexes were dropped with the old table
+	return append(stmts, createIndexesSql(to.Indexes)...)
+}
+
+// tableSql creates the table, with the ddl it was created with when known.
+func tableSql(table *Table) string {
+	if table.Sql != "" {
+		return table.Sql
+	}
+
+	return createTableSql(table, table.Name)
+}
+
+// renameTableSql replaces the table name of a CREATE TABLE statement, as
+// sqlite_master keeps it: its leading keywords upper cased and without IF NOT
+// EXISTS. It's false when the statement isn't one.
+func renameTableSql(createSql string, name string) (string, bool) {
+	rest, ok := strings.CutPrefix(createSql, "CREATE TABLE ")
+	if !ok || rest == "" {
+		return "", false
+	}
+
+	// the name may be quoted in any of the ways sqlite accepts
+	var end int
+	switch rest[0] {
+	case '"', '`':
+		end = quotedIdentEnd(rest)
+	case '[':
+		end = strings.IndexByte(rest, ']') + 1
+	default:
+		end = strings.IndexAny(rest, " (")
+	}
+	if end <= 0 {
+		return "", false
+	}
+
+	return "CREATE TABLE " + quoteIdent(name) + rest[end:], true
+}
+
+// quotedIdentEnd returns the length of the quoted identifier sql starts
+// with, 0 when its quote isn't closed.
+func quotedIdentEnd(sql string) int {
+	quote := sql[0]
+	for i := 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			continue
+		}
+		// doubled quotes are escaped ones
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+
+	return 0
+}
+
+func createTableSql(table *Table, name string) string {
+	lines := make([]string, 0, len(table.Columns)+len(table.Constraints))
+	for _, col := range table.Columns {
+		lines = append(lines, "    "+col.String())
+	}
+	for _, con := range table.Constraints {
+		lines = append(lines, "    CONSTRAINT "+quoteIdent(con.Name)+" "+con.Sql)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", quoteIdent(name), strings.Join(lines, ",\n"))
+}
+
+func createIndexesSql(indexes []Definition) []string {
+	stmts := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		stmts = append(stmts, idx.Sql)
+	}
+
+	return stmts
+}
+
+func dropIndexSql(idx Definition) string {
+	// sqlite keeps triggers with the indexes of their table
+	if strings.HasPrefix(strings.ToUpper(idx.Sql), "CREATE TRIGGER") {
+		return "DROP TRIGGER " + quoteIdent(idx.Name)
+	}
+
+	return "DROP INDEX " + quoteIdent(idx.Name)
+}
+
+var referencesRe = regexp.MustCompile(`REFERENCES ("(?:[^"]|"")+"|[A-Za-z0-9_]+)`)
+
+// sortByDependencies orders tables so the tables referenced by foreign keys
+// come before the tables referencing them. Cycles keep their original order.
+func sortByDependencies(tables []Table) []Table {
+	sorted := make([]Table, 0, len(tables))
+	visited := make(map[string]bool)
+
+	var visit func(table *Table)
+	visit = func(table *Table) {
+		if visited[table.Name] {
+			return
+		}
+		visited[table.Name] = true
+
+		for _, con := range table.Constraints {
+			for _, match := range referencesRe.FindAllStringSubmatch(con.Sql, -1) {
+				refName, _ := splitIdent(match[1])
+				for i := range tables {
+					if tables[i].Name == refName {
+						visit(&tables[i])
+					}
+				}
+			}
+		}
+
+		sorted = append(sorted, *table)
+	}
+
+	for i := range tables {
+		visit(&tables[i])
+	}
+
+	return sorted
+}
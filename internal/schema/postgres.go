@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// IntrospectPostgres reads the schema of the current schema (search_path) of
+// a postgres database from the catalog. References to the schema itself are
+// stripped from definitions, so two schemas with the same objects compare equal.
+func IntrospectPostgres(db *sql.DB) (*Schema, error) {
+	s := &Schema{
+		Driver:    "postgres",
+		Tables:    []Table{},
+		Views:     []Definition{},
+		Functions: []Definition{},
+	}
+
+	var schemaName string
+	if err := db.QueryRow(`SELECT current_schema()`).Scan(&schemaName); err != nil {
+		return nil, err
+	}
+	qualifier := quoteIdent(schemaName) + "."
+
+	// columns, tables are created as they're found
+	rows, err := db.Query(`SELECT c.relname,
+			a.attname,
+			format_type(a.atttypid, a.atttypmod),
+			a.attnotnull,
+			coalesce(pg_get_expr(d.adbin, d.adrelid), '')
+		FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = current_schema()
+			AND c.relkind IN ('r', 'p')
+			AND a.attnum > 0
+			AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum`)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var tableName string
+		var col Column
+		if err := rows.Scan(&tableName, &col.Name, &col.Type, &col.NotNull, &col.Default); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if isTrackingTable(tableName) {
+			continue
+		}
+
+		col.Default = strings.ReplaceAll(col.Default, qualifier, "")
+
+		table := s.FindTable(tableName)
+		if table == nil {
+			s.Tables = append(s.Tables, Table{
+				Name:        tableName,
+				Columns:     []Column{},
+				Constraints: []Definition{},
+				Indexes:     []Definition{},
+			})
+			table = &s.Tables[len(s.Tables)-1]
+		}
+		table.Columns = append(table.Columns, col)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// constraints, not null constraints are already part of the columns
+	err = addTableDefinitions(s, db, qualifier, false, `SELECT c.relname, con.conname, pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+			JOIN pg_class c ON c.oid = con.conrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema()
+			AND con.contype <> 'n'
+		ORDER BY c.relname, con.conname`)
+	if err != nil {
+		return nil, err
+	}
+
+	// indexes that don't back a constraint
+	err = addTableDefinitions(s, db, qualifier, true, `SELECT t.relname, i.relname, pg_get_indexdef(i.oid)
+		FROM pg_index x
+			JOIN pg_class i ON i.oid = x.indexrelid
+			JOIN pg_class t ON t.oid = x.indrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = current_schema()
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = x.indexrelid)
+		ORDER BY t.relname, i.relname`)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Views, err = queryDefinitions(db, qualifier, `SELECT viewname, 'CREATE VIEW ' || quote_ident(viewname) || ' AS ' || definition
+		FROM pg_views
+		WHERE schemaname = current_schema()
+		ORDER BY viewname`); err != nil {
+		return nil, err
+	}
+
+	if s.Functions, err = queryDefinitions(db, qualifier, `SELECT p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+			pg_get_functiondef(p.oid)
+		FROM pg_proc p
+			JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = current_schema()
+			AND p.prokind IN ('f', 'p')
+		ORDER BY 1`); err != nil {
+		return nil, err
+	}
+
+	s.sort()
+	return s, nil
+}
+
+// addTableDefinitions adds the (table, name, sql) rows returned by query to
+// the constraints or indexes of their table.
+func addTableDefinitions(s *Schema, db *sql.DB, qualifier string, indexes bool, query string) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName string
+		var def Definition
+		if err := rows.Scan(&tableName, &def.Name, &def.Sql); err != nil {
+			return err
+		}
+
+		table := s.FindTable(tableName)
+		if table == nil {
+			continue
+		}
+
+		def.Sql = normalizeSql(strings.ReplaceAll(def.Sql, qualifier, ""))
+		if indexes {
+			table.Indexes = append(table.Indexes, def)
+		} else {
+			table.Constraints = append(table.Constraints, def)
+		}
+	}
+
+	return rows.Err()
+}
+
+func queryDefinitions(db *sql.DB, qualifier string, query string) ([]Definition, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := make([]Definition, 0)
+	for rows.Next() {
+		var def Definition
+		if err := rows.Scan(&def.Name, &def.Sql); err != nil {
+			return nil, err
+		}
+
+		// catalog output is already canonical, only the schema is stripped so
+		// function bodies keep their formatting
+		def.Sql = strings.TrimSuffix(strings.TrimSpace(strings.ReplaceAll(def.Sql, qualifier, "")), ";")
+		defs = append(defs, def)
+	}
+
+	return defs, rows.Err()
+}
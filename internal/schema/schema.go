@@ -0,0 +1,308 @@
+package schema
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TrackingTables are valkyrie's own tables, they're never part of a schema.
+var TrackingTables = []string{
+	"migration_group",
+	"migration",
+	"migration_mark",
+	"repeatable_migration",
+	"seed_run",
+}
+
+// Schema is a normalized description of the objects of a database. Every
+// slice is sorted by name so two schemas can be compared or written
+// deterministically.
+type Schema struct {
+	Driver    string
+	Tables    []Table
+	Views     []Definition
+	Functions []Definition
+}
+
+type Table struct {
+	Name        string
+	Columns     []Column
+	Constraints []Definition
+	Indexes     []Definition
+	// Sql is the normalized ddl sqlite created the table with. It's written
+	// by dumps and used to create the table, as the ddl built from the
+	// columns loses rowid aliases and AUTOINCREMENT. Compare ignores it.
+	Sql string
+}
+
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string
+}
+
+// Definition is a named object described by its normalized sql.
+type Definition struct {
+	Name string
+	Sql  string
+}
+
+func (t *Table) FindColumn(name string) *Column {
+	for i, col := range t.Columns {
+		if col.Name == name {
+			return &t.Columns[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) FindTable(name string) *Table {
+	for i, table := range s.Tables {
+		if table.Name == name {
+			return &s.Tables[i]
+		}
+	}
+
+	return nil
+}
+
+func (c Column) String() string {
+	def := quoteIdent(c.Name) + " " + c.Type
+	if c.NotNull {
+		def += " NOT NULL"
+	}
+	if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+
+	return def
+}
+
+func isTrackingTable(name string) bool {
+	for _, table := range TrackingTables {
+		if table == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+var whitespaceRe = regexp.MustCompile(`\s+`)
+
+// normalizeSql collapses whitespace so formatting changes aren't reported as differences.
+func normalizeSql(sql string) string {
+	sql = strings.TrimSpace(whitespaceRe.ReplaceAllString(sql, " "))
+	return strings.TrimSuffix(sql, ";")
+}
+
+var plainIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func quoteIdent(name string) string {
+	if plainIdentRe.MatchString(name) {
+		return name
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (s *Schema) sort() {
+	sort.Slice(s.Tables, func(i, j int) bool { return s.Tables[i].Name < s.Tables[j].Name })
+	sortDefinitions(s.Views)
+	sortDefinitions(s.Functions)
+
+	for i := range s.Tables {
+		sortDefinitions(s.Tables[i].Constraints)
+		sortDefinitions(s.Tables[i].Indexes)
+	}
+}
+
+func sortDefinitions(defs []Definition) {
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+}
+
+const (
+	tableMarker    = "-- table: "
+	indexMarker    = "-- index: "
+	viewMarker     = "-- view: "
+	functionMarker = "-- function: "
+)
+
+// Write dumps the schema as sql. Every object is preceded by a marker comment,
+// which is what Parse reads it back by.
+func (s *Schema) Write(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	fmt.Fprintf(w, "-- valkyrie schema dump (%s)\n", s.Driver)
+
+	for _, table := range s.Tables {
+		fmt.Fprintf(w, "\n%s%s\n", tableMarker, table.Name)
+		fmt.Fprintf(w, "%s;\n", tableSql(&table))
+
+		for _, idx := range table.Indexes {
+			fmt.Fprintf(w, "%s%s/%s\n", indexMarker, table.Name, idx.Name)
+			fmt.Fprintf(w, "%s;\n", idx.Sql)
+		}
+	}
+
+	for _, view := range s.Views {
+		fmt.Fprintf(w, "\n%s%s\n%s;\n", viewMarker, view.Name, view.Sql)
+	}
+
+	for _, fn := range s.Functions {
+		fmt.Fprintf(w, "\n%s%s\n%s;\n", functionMarker, fn.Name, fn.Sql)
+	}
+
+	return w.Flush()
+}
+
+// Parse reads a schema written by Write. Sqlite tables are dumped with the
+// ddl they were created with, which Parse keeps as their Sql without reading
+// their columns, ReplaySqlite reads them fully.
+func Parse(in io.Reader) (*Schema, error) {
+	s := &Schema{
+		Tables:    []Table{},
+		Views:     []Definition{},
+		Functions: []Definition{},
+	}
+
+	// split the dump in blocks, each starting with a marker
+	type block struct {
+		marker string
+		name   string
+		lines  []string
+	}
+	blocks := make([]*block, 0)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var current *block
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "-- valkyrie schema dump (") {
+			s.Driver = strings.TrimSuffix(strings.TrimPrefix(line, "-- valkyrie schema dump ("), ")")
+			continue
+		}
+
+		isMarker := false
+		for _, marker := range []string{tableMarker, indexMarker, viewMarker, functionMarker} {
+			if strings.HasPrefix(line, marker) {
+				current = &block{marker: marker, name: strings.TrimPrefix(line, marker)}
+				blocks = append(blocks, current)
+				isMarker = true
+				break
+			}
+		}
+
+		if !isMarker && current != nil {
+			current.lines = append(current.lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, b := range blocks {
+		body := strings.TrimSpace(strings.Join(b.lines, "\n"))
+		body = strings.TrimSuffix(body, ";")
+
+		switch b.marker {
+		case tableMarker:
+			table, err := parseTable(b.name, b.lines)
+			if err != nil {
+				return nil, err
+			}
+			s.Tables = append(s.Tables, *table)
+		case indexMarker:
+			tableName, indexName, _ := strings.Cut(b.name, "/")
+			table := s.FindTable(tableName)
+			if table == nil {
+				return nil, fmt.Errorf("index '%s' references unknown table '%s'", indexName, tableName)
+			}
+			table.Indexes = append(table.Indexes, Definition{Name: indexName, Sql: body})
+		case viewMarker:
+			s.Views = append(s.Views, Definition{Name: b.name, Sql: body})
+		case functionMarker:
+			s.Functions = append(s.Functions, Definition{Name: b.name, Sql: body})
+		}
+	}
+
+	s.sort()
+	return s, nil
+}
+
+func parseTable(name string, lines []string) (*Table, error) {
+	table := &Table{
+		Name:        name,
+		Columns:     []Column{},
+		Constraints: []Definition{},
+		Indexes:     []Definition{},
+	}
+
+	// the ddl sqlite created the table with, normalized to a single line
+	if body := strings.TrimSpace(strings.Join(lines, "\n")); !strings.Contains(body, "\n") && strings.HasPrefix(body, "CREATE TABLE ") {
+		table.Sql = strings.TrimSuffix(body, ";")
+		return table, nil
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+
+		if line == "" || line == ");" || strings.HasPrefix(line, "CREATE TABLE ") {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "CONSTRAINT "); ok {
+			conName, def := splitIdent(rest)
+			table.Constraints = append(table.Constraints, Definition{Name: conName, Sql: def})
+			continue
+		}
+
+		colName, def := splitIdent(line)
+		if def == "" {
+			return nil, fmt.Errorf("(%s): invalid column definition '%s'", name, line)
+		}
+
+		col := Column{Name: colName}
+		if before, after, found := strings.Cut(def, " DEFAULT "); found {
+			def, col.Default = before, after
+		}
+		if before, found := strings.CutSuffix(def, " NOT NULL"); found {
+			def, col.NotNull = before, true
+		}
+		col.Type = def
+
+		table.Columns = append(table.Columns, col)
+	}
+
+	return table, nil
+}
+
+// splitIdent splits a leading, possibly quoted, identifier from the rest of the line.
+func splitIdent(line string) (string, string) {
+	if strings.HasPrefix(line, `"`) {
+		for i := 1; i < len(line); i++ {
+			if line[i] != '"' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '"' {
+				i++
+				continue
+			}
+			ident := strings.ReplaceAll(line[1:i], `""`, `"`)
+			return ident, strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	ident, rest, _ := strings.Cut(line, " ")
+	return ident, strings.TrimSpace(rest)
+}
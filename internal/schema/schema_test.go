@@ -0,0 +1,309 @@
+package schema_test
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const testDDL = `
+CREATE TABLE orgs (id INTEGER PRIMARY KEY, code TEXT UNIQUE);
+CREATE TABLE "user accounts" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email varchar(50) NOT NULL,
+	name TEXT DEFAULT 'x',
+	org_id INTEGER REFERENCES orgs (id) ON DELETE CASCADE
+);
+CREATE INDEX ix_name ON "user accounts" (name);
+CREATE VIEW v_orgs AS SELECT id,   code FROM orgs;
+CREATE TABLE migration (id INTEGER);
+`
+
+func getSqliteDb(t *testing.T, ddl string) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: opens a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(ddl); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestIntrospectSqlite(t *testing.T) {
+	s, err := schema.IntrospectSqlite(getSqliteDb(t, testDDL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.FindTable("migration") != nil {
+		t.Error("tracking tables should be excluded")
+	}
+
+	users := s.FindTable("user accounts")
+	if users == nil {
+		t.Fatal("expected table 'user accounts'")
+	}
+
+	if email := users.FindColumn("email"); email == nil || email.Type != "VARCHAR(50)" || !email.NotNull {
+		t.Errorf("unexpected email column: %+v", email)
+	}
+
+	if count := len(users.Constraints); count != 2 {
+		t.Errorf("expected 2 constraints, got %v", users.Constraints)
+	}
+
+	if count := len(users.Indexes); count != 1 {
+		t.Errorf("expected 1 index, got %v", users.Indexes)
+	}
+
+	if expected := "CREATE VIEW v_orgs AS SELECT id, code FROM orgs"; len(s.Views) != 1 || s.Views[0].Sql != expected {
+		t.Errorf("expected view '%s', got %v", expected, s.Views)
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	s, err := schema.IntrospectSqlite(getSqliteDb(t, testDDL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// without the sqlite ddl, tables are written and parsed from their columns
+	columnsOnly := *s
+	columnsOnly.Tables = make([]schema.Table, len(s.Tables))
+	for i, table := range s.Tables {
+		table.Sql = ""
+		columnsOnly.Tables[i] = table
+	}
+
+	buf := &bytes.Buffer{}
+	if err := columnsOnly.Write(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parsed, err := schema.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(&columnsOnly, parsed) {
+		t.Errorf("parsed schema doesn't match the written one:\n%s\n%+v\n%+v", buf.String(), &columnsOnly, parsed)
+	}
+}
+
+func TestWriteReplaySqlite(t *testing.T) {
+	s, err := schema.IntrospectSqlite(getSqliteDb(t, testDDL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.Write(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the dump keeps the rowid alias and AUTOINCREMENT of the table ddl
+	if expected := "id INTEGER PRIMARY KEY AUTOINCREMENT"; !bytes.Contains(buf.Bytes(), []byte(expected)) {
+		t.Errorf("expected the dump to contain '%s':\n%s", expected, buf.String())
+	}
+
+	replayed, err := schema.ReplaySqlite(getSqliteDb(t, ""), bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(s, replayed) {
+		t.Errorf("replayed schema doesn't match the written one:\n%s\n%+v\n%+v", buf.String(), s, replayed)
+	}
+
+	parsed, err := schema.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, table := range s.Tables {
+		if parsedTable := parsed.FindTable(table.Name); parsedTable == nil || parsedTable.Sql != table.Sql {
+			t.Errorf("expected table '%s' to be parsed with its ddl, got %+v", table.Name, parsedTable)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	expected := &schema.Schema{
+		Tables: []schema.Table{
+			{
+				Name: "users",
+				Columns: []schema.Column{
+					{Name: "id", Type: "INTEGER", NotNull: true},
+					{Name: "name", Type: "TEXT"},
+				},
+				Indexes: []schema.Definition{{Name: "ix_users_name", Sql: "CREATE INDEX ix_users_name ON users (name)"}},
+			},
+			{Name: "orgs"},
+		},
+	}
+
+	actual := &schema.Schema{
+		Tables: []schema.Table{
+			{
+				Name: "users",
+				Columns: []schema.Column{
+					{Name: "id", Type: "BIGINT", NotNull: true},
+					{Name: "age", Type: "INTEGER"},
+				},
+			},
+			{Name: "teams"},
+		},
+	}
+
+	expectedDiffs := []string{
+		"type change: users.id (expected INTEGER, found BIGINT)",
+		"missing column: users.name",
+		"extra column: users.age",
+		"missing index: users.ix_users_name",
+		"missing table: orgs",
+		"extra table: teams",
+	}
+
+	diffs := schema.Compare(expected, actual)
+	if len(diffs) != len(expectedDiffs) {
+		t.Fatalf("expected '%v' differences, got '%v': %v", len(expectedDiffs), len(diffs), diffs)
+	}
+
+	for i, diff := range diffs {
+		if diff.String() != expectedDiffs[i] {
+			t.Errorf("expected '%v', got '%v'", expectedDiffs[i], diff.String())
+		}
+	}
+
+	if diffs := schema.Compare(expected, expected); len(diffs) != 0 {
+		t.Errorf("expected no differences, got '%v'", diffs)
+	}
+}
+
+func TestMigrationSql(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		current string
+		desired string
+	}{
+		{
+			desc:    "new tables",
+			current: `CREATE TABLE orgs (id INTEGER PRIMARY KEY);`,
+			desired: `CREATE TABLE orgs (id INTEGER PRIMARY KEY);
+				CREATE TABLE teams (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id));
+				CREATE TABLE users (id INTEGER PRIMARY KEY, org_id INTEGER REFERENCES orgs (id));
+				CREATE INDEX ix_users_org ON users (org_id);`,
+		},
+		{
+			desc: "added and dropped columns",
+			current: `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, legacy INTEGER);
+				CREATE INDEX ix_users_name ON users (name);`,
+			desired: `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '', email TEXT);
+				CREATE INDEX ix_users_email ON users (email);`,
+		},
+		{
+			desc: "dropped tables and views",
+			current: `CREATE TABLE orgs (id INTEGER PRIMARY KEY);
+				CREATE TABLE users (id INTEGER PRIMARY KEY, org_id INTEGER REFERENCES orgs (id));
+				CREATE VIEW v_users AS SELECT id FROM users;`,
+			desired: `CREATE TABLE users (id INTEGER PRIMARY KEY);`,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			db := getSqliteDb(t, tC.current)
+
+			current, err := schema.IntrospectSqlite(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			desired, err := schema.IntrospectSqlite(getSqliteDb(t, tC.desired))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, stmt := range schema.MigrationSql(current, desired, "sqlite") {
+				if _, err := db.Exec(stmt); err != nil {
+					t.Fatalf("failed to execute '%s': %v", stmt, err)
+				}
+			}
+
+			migrated, err := schema.IntrospectSqlite(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diffs := schema.Compare(desired, migrated); len(diffs) != 0 {
+				t.Errorf("expected no differences, got '%v'", diffs)
+			}
+		})
+	}
+}
+
+func TestMigrationSqlRebuildKeepsDdl(t *testing.T) {
+	current := `CREATE TABLE "order lines" (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		qty INTEGER CHECK (qty > 0),
+		name TEXT COLLATE NOCASE
+	);`
+	// qty becoming NOT NULL rebuilds the table
+	desired := `CREATE TABLE "order lines" (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		qty INTEGER NOT NULL CHECK (qty > 0),
+		name TEXT COLLATE NOCASE
+	);`
+
+	db := getSqliteDb(t, current+`INSERT INTO "order lines" (qty, name) VALUES (1, 'a');`)
+
+	from, err := schema.IntrospectSqlite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to, err := schema.IntrospectSqlite(getSqliteDb(t, desired))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stmt := range schema.MigrationSql(from, to, "sqlite") {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to execute '%s': %v", stmt, err)
+		}
+	}
+
+	testCases := []struct {
+		desc     string
+		query    string
+		expected int
+	}{
+		{desc: "rows", query: `SELECT count(*) FROM "order lines"`, expected: 1},
+		{desc: "not null", query: `SELECT "notnull" FROM pragma_table_info('order lines') WHERE name = 'qty'`, expected: 1},
+		{desc: "autoincrement", query: `SELECT count(*) FROM sqlite_master WHERE name = 'order lines' AND sql LIKE '%AUTOINCREMENT%'`, expected: 1},
+		{desc: "check", query: `SELECT count(*) FROM sqlite_master WHERE name = 'order lines' AND sql LIKE '%CHECK (qty > 0)%'`, expected: 1},
+		{desc: "collate", query: `SELECT count(*) FROM "order lines" WHERE name = 'A'`, expected: 1},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var count int
+			if err := db.QueryRow(tC.query).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+
+			if count != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// IntrospectSqlite reads the schema of a sqlite database. Columns and
+// constraints come from the table pragmas, indexes, views and triggers keep
+// their sqlite_master ddl. Tables keep it too, for dumps.
+func IntrospectSqlite(db *sql.DB) (*Schema, error) {
+	s := &Schema{
+		Driver:    "sqlite",
+		Tables:    []Table{},
+		Views:     []Definition{},
+		Functions: []Definition{},
+	}
+
+	rows, err := db.Query(`SELECT type, name, tbl_name, coalesce(sql, '')
+		FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%'
+		ORDER BY type, name`)
+	if err != nil {
+		return nil, err
+	}
+
+	type masterRow struct{ objType, name, tableName, sql string }
+	objects := make([]masterRow, 0)
+
+	for rows.Next() {
+		var row masterRow
+		if err := rows.Scan(&row.objType, &row.name, &row.tableName, &row.sql); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		objects = append(objects, row)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		if obj.objType == "table" && !isTrackingTable(obj.name) {
+			table, err := introspectSqliteTable(db, obj.name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read table '%s': %v", obj.name, err)
+			}
+			table.Sql = normalizeSql(obj.sql)
+			s.Tables = append(s.Tables, *table)
+		}
+	}
+
+	for _, obj := range objects {
+		switch obj.objType {
+		case "index", "trigger":
+			// automatic indexes have no sql, they're reported as constraints
+			if obj.sql == "" || isTrackingTable(obj.tableName) {
+				continue
+			}
+
+			table := s.FindTable(obj.tableName)
+			if table == nil {
+				continue
+			}
+			// triggers are kept with the indexes of their table
+			table.Indexes = append(table.Indexes, Definition{Name: obj.name, Sql: normalizeSql(obj.sql)})
+		case "view":
+			s.Views = append(s.Views, Definition{Name: obj.name, Sql: normalizeSql(obj.sql)})
+		}
+	}
+
+	s.sort()
+	return s, nil
+}
+
+// ReplaySqlite runs a sqlite dump written by Write on an empty database and
+// reads its schema back.
+func ReplaySqlite(db *sql.DB, dump io.Reader) (*Schema, error) {
+	buf, err := io.ReadAll(dump)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(string(buf)); err != nil {
+		return nil, fmt.Errorf("failed to replay the dump: %v", err)
+	}
+
+	return IntrospectSqlite(db)
+}
+
+func introspectSqliteTable(db *sql.DB, name string) (*Table, error) {
+	table := &Table{
+		Name:        name,
+		Columns:     []Column{},
+		Constraints: []Definition{},
+		Indexes:     []Definition{},
+	}
+
+	rows, err := db.Query(`SELECT name, type, "notnull", coalesce(dflt_value, ''), pk FROM pragma_table_info(?) ORDER BY cid`, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pkColumns := make(map[int]string)
+	for rows.Next() {
+		var col Column
+		var pk int
+		if err := rows.Scan(&col.Name, &col.Type, &col.NotNull, &col.Default, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		col.Type = strings.ToUpper(col.Type)
+		table.Columns = append(table.Columns, col)
+
+		if pk > 0 {
+			pkColumns[pk] = col.Name
+		}
+	}
+	rows.Close()
+
+	if len(pkColumns) > 0 {
+		cols := make([]string, 0, len(pkColumns))
+		for i := 1; i <= len(pkColumns); i++ {
+			cols = append(cols, quoteIdent(pkColumns[i]))
+		}
+		table.Constraints = append(table.Constraints, Definition{
+			Name: name + "_pkey",
+			Sql:  "PRIMARY KEY (" + strings.Join(cols, ", ") + ")",
+		})
+	}
+
+	uniques, err := sqliteUniqueConstraints(db, name)
+	if err != nil {
+		return nil, err
+	}
+	table.Constraints = append(table.Constraints, uniques...)
+
+	foreignKeys, err := sqliteForeignKeys(db, name)
+	if err != nil {
+		return nil, err
+	}
+	table.Constraints = append(table.Constraints, foreignKeys...)
+
+	return table, nil
+}
+
+// sqliteUniqueConstraints reads the automatic indexes backing UNIQUE constraints.
+func sqliteUniqueConstraints(db *sql.DB, table string) ([]Definition, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_index_list(?) WHERE origin = 'u' ORDER BY name`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	indexNames := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		indexNames = append(indexNames, name)
+	}
+	rows.Close()
+
+	constraints := make([]Definition, 0, len(indexNames))
+	for _, indexName := range indexNames {
+		cols, err := sqliteIndexColumns(db, indexName)
+		if err != nil {
+			return nil, err
+		}
+
+		// automatic index names depend on declaration order, the columns don't
+		constraints = append(constraints, Definition{
+			Name: table + "_" + strings.Join(cols, "_") + "_key",
+			Sql:  "UNIQUE (" + strings.Join(quoteIdents(cols), ", ") + ")",
+		})
+	}
+
+	return constraints, nil
+}
+
+func sqliteIndexColumns(db *sql.DB, index string) ([]string, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+
+	return cols, rows.Err()
+}
+
+func sqliteForeignKeys(db *sql.DB, table string) ([]Definition, error) {
+	rows, err := db.Query(`SELECT id, "table", "from", coalesce("to", ''), on_update, on_delete
+		FROM pragma_foreign_key_list(?)
+		ORDER BY id, seq`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type foreignKey struct {
+		refTable, onUpdate, onDelete string
+		from, to                     []string
+	}
+	keys := make([]*foreignKey, 0)
+	byId := make(map[int]*foreignKey)
+
+	for rows.Next() {
+		var id int
+		var refTable, from, to, onUpdate, onDelete string
+		if err := rows.Scan(&id, &refTable, &from, &to, &onUpdate, &onDelete); err != nil {
+			return nil, err
+		}
+
+		fk, ok := byId[id]
+		if !ok {
+			fk = &foreignKey{refTable: refTable, onUpdate: onUpdate, onDelete: onDelete}
+			byId[id] = fk
+			keys = append(keys, fk)
+		}
+		fk.from = append(fk.from, from)
+		if to != "" {
+			fk.to = append(fk.to, to)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	constraints := make([]Definition, 0, len(keys))
+	for _, fk := range keys {
+		def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", strings.Join(quoteIdents(fk.from), ", "), quoteIdent(fk.refTable))
+		if len(fk.to) > 0 {
+			def += " (" + strings.Join(quoteIdents(fk.to), ", ") + ")"
+		}
+		if fk.onUpdate != "NO ACTION" {
+			def += " ON UPDATE " + fk.onUpdate
+		}
+		if fk.onDelete != "NO ACTION" {
+			def += " ON DELETE " + fk.onDelete
+		}
+
+		constraints = append(constraints, Definition{
+			Name: table + "_" + strings.Join(fk.from, "_") + "_fkey",
+			Sql:  def,
+		})
+	}
+
+	return constraints, nil
+}
+
+func quoteIdents(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+
+	return quoted
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"os"
+
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	desiredFlagName    = "desired"
+	migrationsFlagName = "migrations"
+	nameFlagName       = "name"
+)
+
+func NewDiffCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "diff --desired schema.sql [connFile]",
+		Short: "Generates a migration from a desired schema",
+		Long: `Compares the database with a desired-state schema and generates the ddl to get from one to the other:
+tables, columns, indexes and foreign keys. The desired schema is a sql file (a 'valkyrie dump' snapshot works too)
+or a sqlite database file, to diff two sqlite databases directly.
+With --group the ddl is written as a new dated migration of the group, otherwise it's printed.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			desiredPath, err := cmd.Flags().GetString(desiredFlagName)
+			if err != nil {
+				return err
+			}
+
+			var opts valkyrie.DiffOptions
+			if opts.Group, err = cmd.Flags().GetString(constants.GroupFlagName); err != nil {
+				return err
+			}
+			if opts.MigrationFolder, err = cmd.Flags().GetString(migrationsFlagName); err != nil {
+				return err
+			}
+			if opts.Name, err = cmd.Flags().GetString(nameFlagName); err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 0 {
+				connFilePath = args[0]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.Diff(connString, desiredPath, opts, os.Stdout)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().String(desiredFlagName, "", "desired schema, a sql file or a sqlite database (required)")
+	c.Flags().String(constants.GroupFlagName, "", "group to write the generated migration to")
+	c.Flags().String(migrationsFlagName, "migrations", "migration folder containing the group")
+	c.Flags().String(nameFlagName, "diff", "name of the generated migration, after its date: letters, digits, '_' and '-'")
+	c.MarkFlagRequired(desiredFlagName)
+
+	return c
+}
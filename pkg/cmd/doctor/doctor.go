@@ -0,0 +1,52 @@
+package doctor
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const fixFlagName = "fix"
+
+func NewDoctorCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "doctor [connFile] [--fix]",
+		Short: "Checks the migration tables for inconsistencies",
+		Long:  "Diagnoses missing tracking tables, duplicate groups, orphaned or duplicate migration rows and invalid timestamps. With --fix, the issues are repaired inside a transaction.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			fix, err := cmd.Flags().GetBool(fixFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 0 {
+				connFilePath = args[0]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.Doctor(repo, fix)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().Bool(fixFlagName, false, "repairs the issues found")
+
+	return c
+}
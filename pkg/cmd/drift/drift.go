@@ -0,0 +1,55 @@
+package drift
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const snapshotFlagName = "snapshot"
+
+func NewDriftCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "drift --snapshot schema.sql [connFile]",
+		Short: "Compares the database schema with a committed snapshot",
+		Long: `Introspects the database the same way dump does and reports missing or extra tables, columns, indexes
+and constraints, and type changes, compared to the snapshot. Exits with an error when the schema drifted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			snapshotPath, err := cmd.Flags().GetString(snapshotFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 0 {
+				connFilePath = args[0]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			db, driver, err := valkyrie.OpenDb(connString)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			return valkyrie.Drift(db, driver, snapshotPath)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().String(snapshotFlagName, "", "schema snapshot written by 'valkyrie dump' (required)")
+	c.MarkFlagRequired(snapshotFlagName)
+
+	return c
+}
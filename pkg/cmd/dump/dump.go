@@ -0,0 +1,73 @@
+package dump
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const outFlagName = "out"
+
+func NewDumpCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "dump [connFile] [--out schema.sql]",
+		Short: "Writes a normalized snapshot of the database schema",
+		Long: `Writes the tables, columns, constraints, indexes, views and functions of the database as deterministic sql,
+excluding the migration tables. The snapshot is meant to be committed next to the migrations. It's written to
+stdout unless --out is given.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			outPath, err := cmd.Flags().GetString(outFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 0 {
+				connFilePath = args[0]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			db, driver, err := valkyrie.OpenDb(connString)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if outPath == "" {
+				return valkyrie.Dump(db, driver, os.Stdout)
+			}
+
+			f, err := os.Create(outPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if err := valkyrie.Dump(db, driver, f); err != nil {
+				return err
+			}
+
+			fmt.Printf("schema written to %s\n", outPath)
+			return f.Close()
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().String(outFlagName, "", "file the schema is written to, defaults to stdout")
+
+	return c
+}
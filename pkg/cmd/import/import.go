@@ -0,0 +1,54 @@
+package importCmd
+
+import (
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	fromFlagName     = "from"
+	groupFlagName    = "group"
+	baseDateFlagName = "base-date"
+	reportFlagName   = "report"
+)
+
+func NewImportCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "import --from golang-migrate|goose|flyway <srcDir> <migrationFolder>",
+		Short: "Converts migrations from other tools into a migration group",
+		Long: `Converts golang-migrate ('0001_x.up.sql'), goose ('-- +goose Up' annotations) or flyway ('V1__x.sql') migrations
+into a '<group>/<yyyymmdd>_<description>.sql' layout. Versions starting with a yyyymmdd date keep it,
+sequential versions use --base-date. Down, undo, repeatable and go migrations are skipped and reported.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := cmd.Flags().GetString(fromFlagName)
+			if err != nil {
+				return err
+			}
+
+			opts := valkyrie.ImportOptions{}
+
+			if opts.Group, err = cmd.Flags().GetString(groupFlagName); err != nil {
+				return err
+			}
+
+			if opts.BaseDate, err = cmd.Flags().GetString(baseDateFlagName); err != nil {
+				return err
+			}
+
+			if opts.ReportPath, err = cmd.Flags().GetString(reportFlagName); err != nil {
+				return err
+			}
+
+			return valkyrie.Import(from, args[0], args[1], opts)
+		},
+	}
+
+	c.Flags().String(fromFlagName, "", "tool the migrations were written for: golang-migrate, goose or flyway")
+	c.Flags().String(groupFlagName, "", "group to import into, defaults to the name of the source folder")
+	c.Flags().String(baseDateFlagName, "", "date (yyyymmdd) given to sequentially versioned migrations, defaults to today")
+	c.Flags().String(reportFlagName, "", "writes the json mapping report to the given file")
+	c.MarkFlagRequired(fromFlagName)
+
+	return c
+}
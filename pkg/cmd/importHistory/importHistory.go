@@ -0,0 +1,82 @@
+package importHistory
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	fromFlagName       = "from"
+	mappingFlagName    = "mapping"
+	sourceConnFlagName = "source-conn"
+)
+
+func NewImportHistoryCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "import-history --mapping <mappingFile> [connFile]",
+		Short: "Imports applied migrations from another tool's tracking table",
+		Long: `Reads schema_migrations (golang-migrate), goose_db_version (goose) or flyway_schema_history (flyway)
+and logs the matching valkyrie migrations as applied, so the next migrate doesn't run them again.
+The mapping file is the report written by 'valkyrie import --report'. The tracking table is read from
+the target database unless --source-conn is given.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			from, err := cmd.Flags().GetString(fromFlagName)
+			if err != nil {
+				return err
+			}
+
+			mappingPath, err := cmd.Flags().GetString(mappingFlagName)
+			if err != nil {
+				return err
+			}
+
+			sourceConn, err := cmd.Flags().GetString(sourceConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 0 {
+				connFilePath = args[0]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			if sourceConn == "" {
+				sourceConn = connString
+			}
+
+			sourceDb, _, err := valkyrie.OpenDb(sourceConn)
+			if err != nil {
+				return err
+			}
+			defer sourceDb.Close()
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.ImportHistory(repo, sourceDb, from, mappingPath)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().String(fromFlagName, "", "tool that owns the tracking table, defaults to the one in the mapping file")
+	c.Flags().String(mappingFlagName, "", "mapping report written by 'valkyrie import --report' (required)")
+	c.Flags().String(sourceConnFlagName, "", "connection to the database holding the tracking table, defaults to the target database")
+	c.MarkFlagRequired(mappingFlagName)
+
+	return c
+}
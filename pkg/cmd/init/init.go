@@ -7,11 +7,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const pageSizeFlagName = "page-size"
+
 func NewInitCmd() *cobra.Command {
 	c := &cobra.Command{
 		Use:       "init <connFile> [--conn db-connection]",
 		Short:     "Creates or verifies the connectino to the database.",
-		Long:      "Creates or pings the specified database. The deafult database is used if no config file is specificed.",
+		Long:      "Creates or pings the specified database. The deafult database is used if no config file is specificed. New SQLite databases can be created with a chosen page size and journal mode.",
 		Args:      cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 
@@ -20,25 +22,56 @@ func NewInitCmd() *cobra.Command {
 				return err
 			} 
 			
-			if connString != "" {
-				return valkyrie.Init(connString)
-			}
-
-			if len(args) > 0 {
-				connFilePath := args[0]
+			connFilePath := ""
+			if connString == "" && len(args) > 0 {
+				connFilePath = args[0]
 
 				if connString, err = helpers.GetConnString(connFilePath); err != nil {
 					return err
 				}
-			} else {
+			} else if connString == "" {
 				connString = constants.DefaultDb
 			}
 
-			return valkyrie.Init(connString)
+			driver, err := cmd.Flags().GetString(constants.DriverFlagName)
+			if err != nil {
+				return err
+			}
+
+			wait, err := cmd.Flags().GetDuration(constants.WaitFlagName)
+			if err != nil {
+				return err
+			}
+
+			if wait > 0 {
+				if err := valkyrie.WaitForDb(connString, driver, wait); err != nil {
+					return err
+				}
+			}
+
+			pragmas, err := helpers.GetSqlitePragmas(cmd, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			pageSize, err := cmd.Flags().GetInt(pageSizeFlagName)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.InitWithOptions(connString, valkyrie.InitOptions{
+				Driver:   driver,
+				Pragmas:  pragmas,
+				PageSize: pageSize,
+			})
 		},
 	}
 
 	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.PersistentFlags().String(constants.DriverFlagName, "", "sets the database driver (postgres or sqlite) instead of telling it from the connection string")
+	helpers.AddWaitFlag(c)
+	helpers.AddSqliteFlags(c)
+	c.Flags().Int(pageSizeFlagName, 0, "page size of a new SQLite database, a power of two between 512 and 65536")
 
 	return c
 }
\ No newline at end of file
@@ -0,0 +1,94 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	dialectFlagName = "dialect"
+	ruleFlagName    = "rule"
+	failOnFlagName  = "fail-on"
+)
+
+func NewLintCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "lint <migrationFolder> [connFile]",
+		Short: "Flags risky statements in migration files",
+		Long: `Checks every migration for risky statements: dropped tables or columns, NOT NULL columns added without a default,
+unbounded updates or deletes, creates without IF NOT EXISTS, and dialect specific ones like Postgres indexes built without
+CONCURRENTLY, Postgres column type changes and alters SQLite can't run. The dialect comes from --dialect, or from the
+connection when one is given, otherwise every rule runs.
+Rule severities (off, info, warning or error) are set in the connFile's LintRules section or with --rule, and the command
+fails when a finding reaches --fail-on. A '-- valkyrie-lint-ignore <rule,...>' comment suppresses rules for its statement,
+'-- valkyrie-lint-ignore-file <rule,...>' for the whole file; without rules it suppresses all of them.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 1 {
+				connFilePath = args[1]
+			}
+
+			var opts valkyrie.LintOptions
+			if opts.Dialect, err = cmd.Flags().GetString(dialectFlagName); err != nil {
+				return err
+			}
+			if opts.FailOn, err = cmd.Flags().GetString(failOnFlagName); err != nil {
+				return err
+			}
+			if opts.Include, opts.Exclude, err = helpers.GetGroupFlags(cmd); err != nil {
+				return err
+			}
+
+			if opts.Dialect == "" && (connFlag != "" || connFilePath != "") {
+				connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+				if err != nil {
+					return err
+				}
+
+				if opts.Dialect, err = valkyrie.ResolveDriver(connString); err != nil {
+					return err
+				}
+			}
+
+			if opts.Severities, err = helpers.GetLintRules(connFilePath); err != nil {
+				return err
+			}
+			if opts.Severities == nil {
+				opts.Severities = make(map[string]string)
+			}
+
+			rules, err := cmd.Flags().GetStringSlice(ruleFlagName)
+			if err != nil {
+				return err
+			}
+			for _, rule := range rules {
+				name, severity, ok := strings.Cut(rule, "=")
+				if !ok {
+					return fmt.Errorf("invalid rule '%s', expected <rule>=<severity>", rule)
+				}
+				opts.Severities[name] = severity
+			}
+
+			return valkyrie.Lint(args[0], opts)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().String(dialectFlagName, "", "sql dialect of the migrations: postgres or sqlite. Defaults to the connected database")
+	c.Flags().StringSlice(ruleFlagName, nil, "sets the severity of a rule, as <rule>=<off|info|warning|error> (repeatable)")
+	c.Flags().String(failOnFlagName, "error", "lowest severity that fails the command: info, warning or error")
+	helpers.AddGroupFlags(c)
+
+	return c
+}
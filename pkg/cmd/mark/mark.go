@@ -0,0 +1,65 @@
+package mark
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const reasonFlagName = "reason"
+
+func NewMarkCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "mark",
+		Short: "Manually edits the migration history",
+		Long:  "Marks a single migration as applied or pending without executing it. Every change is recorded with the given reason.",
+	}
+
+	c.AddCommand(
+		newMarkStatusCmd(models.MarkStatusApplied, "Logs a migration as applied without executing it"),
+		newMarkStatusCmd(models.MarkStatusPending, "Removes a migration from the log so it runs on the next migrate"),
+	)
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.PersistentFlags().String(reasonFlagName, "", "why the migration history is being edited (required)")
+
+	return c
+}
+
+func newMarkStatusCmd(status string, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   status + " <group>/<file> [connFile] --reason <reason>",
+		Short: short,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			reason, err := cmd.Flags().GetString(reasonFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 1 {
+				connFilePath = args[1]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.Mark(repo, args[0], status, reason)
+		},
+	}
+}
@@ -3,77 +3,263 @@ package migrate
 import (
 	"errors"
 	"fmt"
-	"path"
-	"strings"
+	"time"
 
 	"github.com/marianop9/valkyrie-migrate/internal/constants"
 	"github.com/marianop9/valkyrie-migrate/internal/helpers"
 	"github.com/marianop9/valkyrie-migrate/internal/models"
-	postgresRepo "github.com/marianop9/valkyrie-migrate/internal/repository/postgres"
-	sqliteRepo "github.com/marianop9/valkyrie-migrate/internal/repository/sqlite"
 	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
 	"github.com/spf13/cobra"
 )
 
+const (
+	dryRunFlagName           = "dry-run"
+	toFlagName               = "to"
+	toDateFlagName           = "to-date"
+	stepsFlagName            = "steps"
+	statementTimeoutFlagName = "statement-timeout"
+	lockTimeoutFlagName      = "lock-timeout"
+	timeoutFlagName          = "timeout"
+	maxAttemptsFlagName      = "max-attempts"
+	retryBackoffFlagName     = "retry-backoff"
+)
+
+// maxRetryBackoff caps the doubling wait between retries.
+const maxRetryBackoff = 30 * time.Second
+
 var ErrNoMigrationFolder = errors.New("the folder containing migrations must be specified")
 
 func NewMigrateCmd() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "migrate <migrationFolder> [connFile]",
 		Short: "Updates the database to the latest migration",
-		Long:  "Updates the database to the latest migration. To specify a database, pass the path to the connFile as the second argument, or specify the connection directly with --conn",
+		Long: `Updates the database to the latest migration. To specify a database, pass the path to the connFile as the second argument, or specify the connection directly with --conn.
+Hook files (_before.sql, _after.sql, _before_each.sql, _after_each.sql) at the root of the migration folder or in a group folder
+run inside the migration transaction, and the connFile's Hooks section can list shell commands for the same points.
+Repeatable migrations (R_ prefixed files, or files in a group's repeatable folder) run after every versioned migration whenever
+their content changes. They're skipped when migrating to a target.
+Timeouts default to the connFile's Timeouts section, and a file can override them with header comments like
+'-- valkyrie:statement-timeout 10m' or '-- valkyrie:lock-timeout 5s'.
+Runs failing with transient errors (lost connections, deadlocks, serialization failures, lock timeouts and busy SQLite
+databases) are rolled back and retried from the first pending migration, up to --max-attempts times.
+SQLite pragmas default to the connFile's Sqlite section, and a file can toggle foreign_keys or legacy_alter_table with
+'-- valkyrie:pragma foreign_keys=OFF', set on the connection before the run's transaction begins and restored after it.`,
 		Args:  cobra.MatchAll(cobra.MinimumNArgs(1), cobra.MaximumNArgs(2)),
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			var (
-				migrationFolder, connString string
-			)
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			opts, err := GetPlanOptions(cmd)
+			if err != nil {
+				return err
+			}
 
-			connString, err := cmd.Flags().GetString(constants.ConnFlagName)
+			dryRun, err := cmd.Flags().GetBool(dryRunFlagName)
 			if err != nil {
 				return err
 			}
 
 			if len(args) == 0 {
 				return ErrNoMigrationFolder
-			} else {
-				migrationFolder = args[0]
 			}
+			migrationFolder := args[0]
 
-			if connString == "" {
-				if len(args) > 1 {
-					if connString, err = helpers.GetConnString(args[1]); err != nil {
-						return err
-					}
-				} else {
-					connString = constants.DefaultDb
-				}
+			connFilePath := ""
+			if len(args) > 1 {
+				connFilePath = args[1]
 			}
 
-			var migrationRepo models.MigrationStorer
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
 
-			if strings.HasPrefix(connString, "postgresql://") {
-				db, err := helpers.GetPostgresDb(connString)
-				if err != nil {
-					return err
-				}
-				migrationRepo = postgresRepo.NewMigrationRepo(db)
+			hookCommands, err := helpers.GetHookCommands(connFilePath)
+			if err != nil {
+				return err
+			}
+
+			driver, err := cmd.Flags().GetString(constants.DriverFlagName)
+			if err != nil {
+				return err
+			}
 
-			} else if path.Ext(connString) == ".db" {
-				db, err := helpers.GetDb(connString)
-				if err != nil {
+			pragmas, err := helpers.GetSqlitePragmas(cmd, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			if connString, err = valkyrie.ApplySqlitePragmas(connString, driver, pragmas); err != nil {
+				return err
+			}
+
+			wait, err := cmd.Flags().GetDuration(constants.WaitFlagName)
+			if err != nil {
+				return err
+			}
+
+			if wait > 0 {
+				if err := valkyrie.WaitForDb(connString, driver, wait); err != nil {
 					return err
 				}
-				migrationRepo = sqliteRepo.NewMigrationRepo(db)
-			} else {
-				return fmt.Errorf("invalid database file extension")
 			}
 
-			return valkyrie.NewMigrateApp(migrationRepo).Run(migrationFolder)
+			migrationRepo, err := valkyrie.NewMigrationStorerWithDriver(connString, driver)
+			if err != nil {
+				return err
+			}
+
+			timeoutOpts, err := getTimeoutOptions(cmd, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			opts = append(opts, valkyrie.WithDryRun(dryRun), valkyrie.WithHookCommands(hookCommands))
+			opts = append(opts, timeoutOpts...)
+
+			retryPolicy, err := getRetryPolicy(cmd)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, valkyrie.WithRetry(retryPolicy))
+
+			return valkyrie.NewMigrateApp(migrationRepo, opts...).Run(migrationFolder)
 		},
 	}
 
 	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.PersistentFlags().String(constants.DriverFlagName, "", "sets the database driver (postgres or sqlite) instead of telling it from the connection string")
+	c.Flags().Bool(dryRunFlagName, false, "prints the pending migrations without executing them")
+	c.Flags().Duration(statementTimeoutFlagName, 0, "cancels statements running longer than this, like 30s (0 for no limit)")
+	c.Flags().Duration(lockTimeoutFlagName, 0, "cancels statements waiting for a lock longer than this, like 5s (0 for no limit)")
+	c.Flags().Duration(timeoutFlagName, 0, "cancels the whole run when it takes longer than this, like 10m (0 for no limit)")
+	c.Flags().Int(maxAttemptsFlagName, 3, "attempts at running the migrations when they fail with transient errors (1 to never retry)")
+	c.Flags().Duration(retryBackoffFlagName, time.Second, "wait before the first retry, doubling after every other")
+	helpers.AddWaitFlag(c)
+	helpers.AddSqliteFlags(c)
+	AddPlanFlags(c)
 
 	return c
 }
+
+// AddPlanFlags registers the flags that shape the migration plan: group
+// filters, the out-of-order policy and the run target.
+func AddPlanFlags(c *cobra.Command) {
+	helpers.AddGroupFlags(c)
+	c.Flags().String(constants.OutOfOrderFlagName, string(valkyrie.OutOfOrderWarn), "policy for migrations dated before applied ones: allow, warn or refuse")
+	c.Flags().String(toFlagName, "", "stops after applying the given migration (<group>/<file>)")
+	c.Flags().String(toDateFlagName, "", "only applies migrations dated on or before the given date (yyyymmdd)")
+	c.Flags().Int(stepsFlagName, 0, "only applies the first n pending migrations")
+	c.MarkFlagsMutuallyExclusive(toFlagName, toDateFlagName, stepsFlagName)
+}
+
+// GetPlanOptions reads the flags registered by AddPlanFlags.
+func GetPlanOptions(cmd *cobra.Command) ([]valkyrie.MigrateOption, error) {
+	outOfOrder, err := cmd.Flags().GetString(constants.OutOfOrderFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := valkyrie.ParseOutOfOrderPolicy(outOfOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	include, exclude, err := helpers.GetGroupFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := getTarget(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return []valkyrie.MigrateOption{
+		valkyrie.WithOutOfOrderPolicy(policy),
+		valkyrie.WithTarget(target),
+		valkyrie.WithGroups(include, exclude),
+	}, nil
+}
+
+func getTarget(cmd *cobra.Command) (*valkyrie.MigrationTarget, error) {
+	to, err := cmd.Flags().GetString(toFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	toDate, err := cmd.Flags().GetString(toDateFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := cmd.Flags().GetInt(stepsFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	return valkyrie.NewMigrationTarget(to, toDate, steps)
+}
+
+// getTimeoutOptions reads the timeout flags, falling back to the defaults of
+// the config file.
+func getTimeoutOptions(cmd *cobra.Command, connFilePath string) ([]valkyrie.MigrateOption, error) {
+	config, err := helpers.GetTimeoutConfig(connFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := getTimeout(cmd, statementTimeoutFlagName, config.Statement)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := getTimeout(cmd, lockTimeoutFlagName, config.Lock)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := getTimeout(cmd, timeoutFlagName, config.Run)
+	if err != nil {
+		return nil, err
+	}
+
+	return []valkyrie.MigrateOption{
+		valkyrie.WithTimeouts(models.Timeouts{Statement: statement, Lock: lock}),
+		valkyrie.WithRunTimeout(run),
+	}, nil
+}
+
+func getTimeout(cmd *cobra.Command, flagName string, configValue string) (time.Duration, error) {
+	if cmd.Flags().Changed(flagName) || configValue == "" {
+		return cmd.Flags().GetDuration(flagName)
+	}
+
+	timeout, err := time.ParseDuration(configValue)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s' in config file: %v", flagName, configValue, err)
+	}
+
+	return timeout, nil
+}
+
+func getRetryPolicy(cmd *cobra.Command) (valkyrie.RetryPolicy, error) {
+	maxAttempts, err := cmd.Flags().GetInt(maxAttemptsFlagName)
+	if err != nil {
+		return valkyrie.RetryPolicy{}, err
+	}
+
+	backoff, err := cmd.Flags().GetDuration(retryBackoffFlagName)
+	if err != nil {
+		return valkyrie.RetryPolicy{}, err
+	}
+
+	return valkyrie.RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		MaxBackoff:  maxRetryBackoff,
+	}, nil
+}
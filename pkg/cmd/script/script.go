@@ -0,0 +1,113 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/migrate"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outFlagName      = "out"
+	baselineFlagName = "baseline"
+	dialectFlagName  = "dialect"
+)
+
+var ErrNoDialect = errors.New("--dialect must be set to postgres or sqlite when generating from the baseline")
+
+func NewScriptCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "script <migrationFolder> [connFile] --out <file>",
+		Short: "Writes the pending migrations as a single sql script",
+		Long: `Computes the pending migrations like migrate does and writes them to a single sql script, wrapped in a
+transaction and followed by the statements that log them in the migration tables. The script includes the
+sql hook files and the timeout and pragma headers of the files. The database is only read, missing migration
+tables count as nothing applied. With --baseline, the script is generated for an empty database and no
+connection is made.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			outPath, err := cmd.Flags().GetString(outFlagName)
+			if err != nil {
+				return err
+			}
+
+			baseline, err := cmd.Flags().GetBool(baselineFlagName)
+			if err != nil {
+				return err
+			}
+
+			driver, err := cmd.Flags().GetString(dialectFlagName)
+			if err != nil {
+				return err
+			}
+
+			opts, err := migrate.GetPlanOptions(cmd)
+			if err != nil {
+				return err
+			}
+
+			var repo models.MigrationStorer
+
+			if baseline {
+				if driver == "" {
+					return ErrNoDialect
+				}
+				repo = valkyrie.NewBaselineStorer()
+			} else {
+				connFilePath := ""
+				if len(args) > 1 {
+					connFilePath = args[1]
+				}
+
+				connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+				if err != nil {
+					return err
+				}
+
+				db, dbDriver, err := valkyrie.OpenDb(connString)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+
+				if driver == "" {
+					driver = dbDriver
+				}
+				repo = valkyrie.NewMigrationStorerForDb(db, dbDriver)
+			}
+
+			f, err := os.Create(outPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if err := valkyrie.NewMigrateApp(repo, opts...).Script(args[0], driver, f); err != nil {
+				return err
+			}
+
+			fmt.Printf("script written to %s\n", outPath)
+			return f.Close()
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().String(outFlagName, "", "file the script is written to (required)")
+	c.Flags().Bool(baselineFlagName, false, "generates the script for an empty database, without connecting")
+	c.Flags().String(dialectFlagName, "", "sql dialect of the script: postgres or sqlite. Defaults to the connected database")
+	c.MarkFlagRequired(outFlagName)
+	migrate.AddPlanFlags(c)
+
+	return c
+}
@@ -0,0 +1,79 @@
+package seed
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	profileFlagName    = "profile"
+	modeFlagName       = "mode"
+	resetFlagName      = "reset"
+	migrationsFlagName = "migrations"
+)
+
+func NewSeedCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "seed <seedFolder> [connFile]",
+		Short: "Loads seed data, tracked apart from the schema migrations",
+		Long: `Runs the sql files of the selected profile folders of the seed folder (for example seeds/dev), in name order.
+Seeds are logged in their own table instead of the migration history. By default only new seeds run, --mode changed
+re-runs the ones whose content changed (write them as upserts) and --mode all re-runs every seed. --reset runs each
+profile's _reset.sql and every seed again. The pending schema migrations of the --migrations folder are applied first,
+so seeds always run on the latest schema.`,
+		Args: cobra.MatchAll(cobra.MinimumNArgs(1), cobra.MaximumNArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 1 {
+				connFilePath = args[1]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			var opts valkyrie.SeedOptions
+			if opts.Profiles, err = cmd.Flags().GetStringSlice(profileFlagName); err != nil {
+				return err
+			}
+			if opts.Reset, err = cmd.Flags().GetBool(resetFlagName); err != nil {
+				return err
+			}
+			if opts.MigrationFolder, err = cmd.Flags().GetString(migrationsFlagName); err != nil {
+				return err
+			}
+
+			mode, err := cmd.Flags().GetString(modeFlagName)
+			if err != nil {
+				return err
+			}
+			if opts.Mode, err = valkyrie.ParseSeedMode(mode); err != nil {
+				return err
+			}
+
+			hookCommands, err := helpers.GetHookCommands(connFilePath)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.Seed(connString, args[0], opts, valkyrie.WithHookCommands(hookCommands))
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	c.Flags().StringSlice(profileFlagName, []string{"default"}, "profile folders to seed, in order (repeatable)")
+	c.Flags().String(modeFlagName, string(valkyrie.SeedPending), "seeds to run: pending, changed or all")
+	c.Flags().Bool(resetFlagName, false, "runs the profiles' _reset.sql and every seed again")
+	c.Flags().String(migrationsFlagName, "", "migration folder applied before seeding (required)")
+	c.MarkFlagRequired(migrationsFlagName)
+
+	return c
+}
@@ -0,0 +1,63 @@
+package squash
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+const (
+	beforeFlagName = "before"
+	fromFlagName   = "from"
+)
+
+func NewSquashCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "squash <migrationFolder> <group> --before yyyymmdd [connFile...]",
+		Short: "Replaces a group's old migrations with a single baseline",
+		Long: `Replaces the migrations of the group dated before the given date with one baseline file, built from
+their concatenated sql or from a dump of the schema they create. Every target database that applied them logs
+the baseline as applied, so only fresh installs run it. Pass every database of the environment at once, they're
+all checked before anything changes.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			var opts valkyrie.SquashOptions
+			if opts.Before, err = cmd.Flags().GetString(beforeFlagName); err != nil {
+				return err
+			}
+			if opts.From, err = cmd.Flags().GetString(fromFlagName); err != nil {
+				return err
+			}
+
+			connStrings := make([]string, 0)
+			if connFlag != "" {
+				connStrings = append(connStrings, connFlag)
+			}
+			for _, connFilePath := range args[2:] {
+				connString, err := helpers.GetConnString(connFilePath)
+				if err != nil {
+					return err
+				}
+				connStrings = append(connStrings, connString)
+			}
+			if len(connStrings) == 0 {
+				connStrings = append(connStrings, constants.DefaultDb)
+			}
+
+			return valkyrie.Squash(args[0], args[1], connStrings, opts)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, added to the conn files")
+	c.Flags().String(beforeFlagName, "", "squashes the migrations dated before this date (yyyymmdd, required)")
+	c.Flags().String(fromFlagName, valkyrie.SquashFromSql, "builds the baseline from the files' sql or from a schema dump: sql or dump")
+	c.MarkFlagRequired(beforeFlagName)
+
+	return c
+}
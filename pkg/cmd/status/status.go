@@ -0,0 +1,66 @@
+package status
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+func NewStatusCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "status <migrationFolder> [connFile]",
+		Short: "Shows applied and pending migrations",
+		Long:  "Compares the migration folder with the database and lists the pending migrations of each group, flagging out-of-order migrations.",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			outOfOrder, err := cmd.Flags().GetString(constants.OutOfOrderFlagName)
+			if err != nil {
+				return err
+			}
+
+			policy, err := valkyrie.ParseOutOfOrderPolicy(outOfOrder)
+			if err != nil {
+				return err
+			}
+
+			include, exclude, err := helpers.GetGroupFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 1 {
+				connFilePath = args[1]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				return err
+			}
+
+			app := valkyrie.NewMigrateApp(repo,
+				valkyrie.WithOutOfOrderPolicy(policy),
+				valkyrie.WithGroups(include, exclude),
+			)
+
+			return app.Status(args[0])
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+	helpers.AddGroupFlags(c)
+	c.Flags().String(constants.OutOfOrderFlagName, string(valkyrie.OutOfOrderWarn), "policy for migrations dated before applied ones: allow, warn or refuse")
+
+	return c
+}
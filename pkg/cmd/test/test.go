@@ -0,0 +1,41 @@
+package test
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+func NewTestCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "test <migrationFolder>",
+		Short: "Round-trips the migrations on a scratch database",
+		Long: `Applies every migration to a scratch database, reverts them in reverse order with their down scripts
+(<migration>.down.sql) and applies them again, checking the schema after each step. Files that fail or leave a
+different schema are reported as not reversible or not idempotent.
+The scratch database is an in-memory SQLite database, or a temporary schema of the Postgres database given with --conn.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			include, exclude, err := helpers.GetGroupFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.RoundTrip(args[0], connFlag,
+				valkyrie.WithOutOfOrderPolicy(valkyrie.OutOfOrderAllow),
+				valkyrie.WithGroups(include, exclude),
+			)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "postgres database to create the scratch schema in, defaults to in-memory SQLite")
+	helpers.AddGroupFlags(c)
+
+	return c
+}
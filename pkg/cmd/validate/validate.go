@@ -0,0 +1,28 @@
+package validate
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+func NewValidateCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "validate <migrationFolder>",
+		Short: "Checks the migration folder layout",
+		Long:  "Checks that the migration folder only contains group subfolders with correctly named sql files. No database connection is needed.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			include, exclude, err := helpers.GetGroupFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.Validate(args[0], include, exclude)
+		},
+	}
+
+	helpers.AddGroupFlags(c)
+
+	return c
+}
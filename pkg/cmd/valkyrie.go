@@ -1,8 +1,23 @@
 package cmd
 
 import (
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/diff"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/doctor"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/drift"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/dump"
+	importCmd "github.com/marianop9/valkyrie-migrate/pkg/cmd/import"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/importHistory"
 	initCmd "github.com/marianop9/valkyrie-migrate/pkg/cmd/init"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/lint"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/mark"
 	"github.com/marianop9/valkyrie-migrate/pkg/cmd/migrate"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/script"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/seed"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/squash"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/status"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/test"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/validate"
+	"github.com/marianop9/valkyrie-migrate/pkg/cmd/verifyHistory"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +30,21 @@ func NewValkyrieCmd() *cobra.Command {
 	rootCmd.AddCommand(
 		migrate.NewMigrateCmd(),
 		initCmd.NewInitCmd(),
+		mark.NewMarkCmd(),
+		doctor.NewDoctorCmd(),
+		status.NewStatusCmd(),
+		validate.NewValidateCmd(),
+		importCmd.NewImportCmd(),
+		importHistory.NewImportHistoryCmd(),
+		script.NewScriptCmd(),
+		dump.NewDumpCmd(),
+		drift.NewDriftCmd(),
+		verifyHistory.NewVerifyHistoryCmd(),
+		diff.NewDiffCmd(),
+		squash.NewSquashCmd(),
+		test.NewTestCmd(),
+		seed.NewSeedCmd(),
+		lint.NewLintCmd(),
 	)
 
 	return rootCmd
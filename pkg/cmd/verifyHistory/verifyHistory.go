@@ -0,0 +1,44 @@
+package verifyHistory
+
+import (
+	"github.com/marianop9/valkyrie-migrate/internal/constants"
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+	"github.com/spf13/cobra"
+)
+
+func NewVerifyHistoryCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "verify-history <migrationFolder> [connFile]",
+		Short: "Checks that replaying every migration reproduces the database schema",
+		Long: `Applies the whole migration folder from scratch to a shadow database (an in-memory SQLite database
+or a temporary Postgres schema) and compares the resulting schema with the target database.
+Any difference means a fresh install and the target database have diverged. Every group is
+applied, as the whole target schema is compared.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connFlag, err := cmd.Flags().GetString(constants.ConnFlagName)
+			if err != nil {
+				return err
+			}
+
+			connFilePath := ""
+			if len(args) > 1 {
+				connFilePath = args[1]
+			}
+
+			connString, err := helpers.ResolveConnString(connFlag, connFilePath)
+			if err != nil {
+				return err
+			}
+
+			return valkyrie.VerifyHistory(args[0], connString,
+				valkyrie.WithOutOfOrderPolicy(valkyrie.OutOfOrderAllow),
+			)
+		},
+	}
+
+	c.PersistentFlags().String(constants.ConnFlagName, "", "directly specifies a db connection, ignoring the config file")
+
+	return c
+}
@@ -0,0 +1,115 @@
+package valkyrie
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	postgresRepo "github.com/marianop9/valkyrie-migrate/internal/repository/postgres"
+	sqliteRepo "github.com/marianop9/valkyrie-migrate/internal/repository/sqlite"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite"
+)
+
+// NewMigrationStorer connects to the database referenced by connString and
+// returns the repository matching its driver.
+func NewMigrationStorer(connString string) (models.MigrationStorer, error) {
+	return NewMigrationStorerWithDriver(connString, "")
+}
+
+// NewMigrationStorerWithDriver is NewMigrationStorer with an explicit driver,
+// resolved from connString when empty.
+func NewMigrationStorerWithDriver(connString string, driver string) (models.MigrationStorer, error) {
+	db, driver, err := OpenDbWithDriver(connString, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMigrationStorerForDb(db, driver), nil
+}
+
+// NewMigrationStorerForDb returns the repository for an already open database.
+func NewMigrationStorerForDb(db *sql.DB, driver string) models.MigrationStorer {
+	if driver == DriverPostgres {
+		return postgresRepo.NewMigrationRepo(db)
+	}
+
+	return sqliteRepo.NewMigrationRepo(db)
+}
+
+// NewSeedStorerForDb returns the seed repository for an already open database.
+func NewSeedStorerForDb(db *sql.DB, driver string) models.SeedStorer {
+	if driver == DriverPostgres {
+		return postgresRepo.NewMigrationRepo(db)
+	}
+
+	return sqliteRepo.NewMigrationRepo(db)
+}
+
+// OpenDb opens the database referenced by connString, returning the name of
+// the driver it resolved to.
+func OpenDb(connString string) (*sql.DB, string, error) {
+	return OpenDbWithDriver(connString, "")
+}
+
+// OpenDbWithDriver is OpenDb with an explicit driver, resolved from
+// connString when empty.
+func OpenDbWithDriver(connString string, driver string) (*sql.DB, string, error) {
+	driver, err := ResolveDriverWithOverride(connString, driver)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if driver == DriverPostgres {
+		db, err := helpers.GetPostgresDb(connString)
+		return db, driver, err
+	}
+
+	db, err := helpers.GetDb(connString)
+	return db, driver, err
+}
+
+const (
+	waitBackoff    = 500 * time.Millisecond
+	maxWaitBackoff = 5 * time.Second
+)
+
+// WaitForDb connects to the database referenced by connString, with the
+// given driver or the one it resolves to, retrying with
+// backoff until it's reachable or timeout passes. It lets valkyrie start
+// alongside a database that's still booting, like in an init container.
+func WaitForDb(connString string, driver string, timeout time.Duration) error {
+	driver, err := ResolveDriverWithOverride(connString, driver)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	wait := waitBackoff
+
+	for {
+		db, _, err := OpenDbWithDriver(connString, driver)
+		if err == nil {
+			return db.Close()
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("database still unreachable after waiting %v: %w", timeout, err)
+		} else if wait > remaining {
+			wait = remaining
+		}
+
+		fmt.Printf("database unreachable, retrying in %v: %v\n", wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+
+		if wait *= 2; wait > maxWaitBackoff {
+			wait = maxWaitBackoff
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+// DiffOptions sets where Diff writes the generated migration. Without a group
+// the statements are written to the output instead of a migration file.
+type DiffOptions struct {
+	MigrationFolder string
+	Group           string
+	Name            string
+}
+
+// Diff generates the ddl needed to turn the schema of the database referenced
+// by connString into the desired one, read from desiredPath.
+func Diff(connString string, desiredPath string, opts DiffOptions, out io.Writer) error {
+	db, driver, err := OpenDb(connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	current, err := IntrospectSchema(db, driver)
+	if err != nil {
+		return err
+	}
+
+	desired, err := LoadDesiredSchema(desiredPath, connString, driver)
+	if err != nil {
+		return err
+	}
+
+	stmts := schema.MigrationSql(current, desired, driver)
+	if len(stmts) == 0 {
+		fmt.Println("the database schema already matches the desired schema")
+		return nil
+	}
+
+	// header directives must lead the file
+	header := ""
+	for _, directive := range schema.MigrationHeader(current, desired, driver) {
+		header += directive + "\n"
+	}
+
+	content := fmt.Sprintf("%s-- generated by valkyrie diff from %s\n\n%s;\n", header, path.Base(desiredPath), strings.Join(stmts, ";\n\n"))
+
+	if opts.Group == "" {
+		_, err := io.WriteString(out, content)
+		return err
+	}
+
+	return writeMigrationFile(opts, content)
+}
+
+// LoadDesiredSchema reads a desired schema: either a sqlite database file, or
+// a sql file applied to a shadow database of the given driver.
+func LoadDesiredSchema(desiredPath string, connString string, driver string) (*schema.Schema, error) {
+	if isSqliteFile(desiredPath) {
+		if driver != DriverSqlite {
+			return nil, fmt.Errorf("can't diff a %s database against the sqlite database '%s'", driver, desiredPath)
+		}
+
+		// opening a missing sqlite file would create it
+		if _, err := os.Stat(desiredPath); err != nil {
+			return nil, err
+		}
+
+		db, err := helpers.GetDb(desiredPath)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+
+		return schema.IntrospectSqlite(db)
+	}
+
+	desiredSql, err := os.ReadFile(desiredPath)
+	if err != nil {
+		return nil, err
+	}
+
+	shadow, err := OpenShadowDb(connString, driver)
+	if err != nil {
+		return nil, err
+	}
+	defer shadow.Close()
+
+	if _, err := shadow.Db.Exec(string(desiredSql)); err != nil {
+		return nil, fmt.Errorf("failed to load desired schema '%s': %v", desiredPath, err)
+	}
+
+	return shadow.Schema()
+}
+
+func writeMigrationFile(opts DiffOptions, content string) error {
+	if opts.Group == "." || opts.Group == ".." || strings.ContainsAny(opts.Group, `/\`) {
+		return fmt.Errorf("invalid group '%s', groups are folders at the root of the migration folder", opts.Group)
+	}
+
+	if err := migrations.CheckDescription(opts.Name); err != nil {
+		return fmt.Errorf("invalid --name: %v", err)
+	}
+
+	groupPath := path.Join(opts.MigrationFolder, opts.Group)
+	if err := os.MkdirAll(groupPath, 0755); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%s_%s.sql", time.Now().Format("20060102"), opts.Name)
+	filePath := path.Join(groupPath, fileName)
+
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("migration '%s' already exists, choose another --name", filePath)
+		}
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.WriteString(f, content); err != nil {
+		return err
+	}
+
+	fmt.Printf("created migration %s/%s\n", opts.Group, fileName)
+	return nil
+}
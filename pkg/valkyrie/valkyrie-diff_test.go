@@ -0,0 +1,147 @@
+package valkyrie_test
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestDiffRebuildKeepsReferencingRows(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	desiredPath := path.Join(dir, "desired.sql")
+	connString := path.Join(dir, "app.db") + "?_foreign_keys=on"
+
+	db, err := helpers.GetDb(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE orgs (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE users (id INTEGER PRIMARY KEY, org_id INTEGER REFERENCES orgs (id) ON DELETE CASCADE);
+		INSERT INTO orgs (id, name) VALUES (1, 'acme');
+		INSERT INTO users (id, org_id) VALUES (1, 1), (2, 1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the type change of orgs.name rebuilds orgs, which users references
+	desired := `CREATE TABLE orgs (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '');
+		CREATE TABLE users (id INTEGER PRIMARY KEY, org_id INTEGER REFERENCES orgs (id) ON DELETE CASCADE);`
+	if err := os.WriteFile(desiredPath, []byte(desired), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := valkyrie.DiffOptions{MigrationFolder: migrationFolder, Group: "Orgs", Name: "rebuild_orgs"}
+	if err := valkyrie.Diff(connString, desiredPath, opts, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(path.Join(migrationFolder, "Orgs"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single migration, got %v: %v", entries, err)
+	}
+
+	content, err := os.ReadFile(path.Join(migrationFolder, "Orgs", entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "-- valkyrie:pragma foreign_keys=OFF\n"; !strings.HasPrefix(string(content), expected) {
+		t.Errorf("expected the migration to start with '%s', got:\n%s", expected, content)
+	}
+
+	repo, err := valkyrie.NewMigrationStorer(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		query    string
+		expected int
+	}{
+		{
+			desc:     "referencing rows",
+			query:    "SELECT count(*) FROM users WHERE org_id = 1",
+			expected: 2,
+		},
+		{
+			desc:     "rebuilt rows",
+			query:    "SELECT count(*) FROM orgs WHERE name = 'acme'",
+			expected: 1,
+		},
+		{
+			desc:     "rebuilt column",
+			query:    `SELECT "notnull" FROM pragma_table_info('orgs') WHERE name = 'name'`,
+			expected: 1,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var count int
+			if err := db.QueryRow(tC.query).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+
+			if count != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, count)
+			}
+		})
+	}
+}
+
+func TestDiffMigrationName(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		group       string
+		name        string
+		expectedErr bool
+	}{
+		{desc: "valid name", group: "Users", name: "add_users-table"},
+		{desc: "parent folder", group: "Users", name: "../../escape", expectedErr: true},
+		{desc: "path separator", group: "Users", name: "nested/name", expectedErr: true},
+		{desc: "windows path separator", group: "Users", name: `nested\name`, expectedErr: true},
+		{desc: "spaces", group: "Users", name: "add users", expectedErr: true},
+		{desc: "down script", group: "Users", name: "add_users.down", expectedErr: true},
+		{desc: "empty name", group: "Users", name: "", expectedErr: true},
+		{desc: "nested group", group: "Users/nested", name: "add_users", expectedErr: true},
+		{desc: "parent group", group: "..", name: "add_users", expectedErr: true},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+			desiredPath := path.Join(dir, "desired.sql")
+
+			if err := os.WriteFile(desiredPath, []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := valkyrie.DiffOptions{MigrationFolder: migrationFolder, Group: tC.group, Name: tC.name}
+			err := valkyrie.Diff(path.Join(dir, "app.db"), desiredPath, opts, io.Discard)
+
+			if tC.expectedErr && err == nil {
+				t.Error("expected an error")
+			} else if !tC.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if tC.expectedErr {
+				if _, err := os.Stat(migrationFolder); !os.IsNotExist(err) {
+					t.Errorf("expected no migration to be written, got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+var ErrTrackingIssues = errors.New("the migration tracking tables are inconsistent. Run with --fix to repair them")
+
+// Doctor checks the migration tracking tables for inconsistencies. When fix is
+// set, the issues are repaired in a single transaction.
+func Doctor(repo models.MigrationStorer, fix bool) error {
+	var issues []models.TrackingIssue
+	var err error
+
+	if fix {
+		issues, err = repo.Repair()
+	} else {
+		issues, err = repo.Diagnose()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(issues) == 0 {
+		fmt.Println("no issues found in the migration tables")
+		return nil
+	}
+
+	fmt.Printf("found %v issue(s):\n", len(issues))
+	for _, issue := range issues {
+		fmt.Printf("* %s: %s\n", issue.Kind, issue.Detail)
+	}
+
+	if !fix {
+		return ErrTrackingIssues
+	}
+
+	fmt.Println("issues repaired")
+	return nil
+}
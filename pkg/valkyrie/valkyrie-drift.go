@@ -0,0 +1,66 @@
+package valkyrie
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+var ErrSchemaDrift = errors.New("the database schema doesn't match the snapshot")
+
+// Drift compares the live database schema with a snapshot written by Dump and
+// returns ErrSchemaDrift when they differ.
+func Drift(db *sql.DB, driver string, snapshotPath string) error {
+	buf, err := os.ReadFile(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := schema.Parse(bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot '%s': %v", snapshotPath, err)
+	}
+
+	if snapshot.Driver != "" && snapshot.Driver != driver {
+		return fmt.Errorf("snapshot was dumped from %s, the database is %s", snapshot.Driver, driver)
+	}
+
+	// sqlite tables are dumped with their ddl, so the snapshot is replayed to
+	// read their columns
+	if driver == DriverSqlite {
+		shadow, err := OpenShadowDb("", DriverSqlite)
+		if err != nil {
+			return err
+		}
+		defer shadow.Close()
+
+		if snapshot, err = schema.ReplaySqlite(shadow.Db, bytes.NewReader(buf)); err != nil {
+			return fmt.Errorf("failed to read snapshot '%s': %v", snapshotPath, err)
+		}
+	}
+
+	live, err := IntrospectSchema(db, driver)
+	if err != nil {
+		return err
+	}
+
+	return reportDifferences(schema.Compare(snapshot, live), "snapshot", ErrSchemaDrift)
+}
+
+func reportDifferences(diffs []schema.Difference, expectedName string, errDiff error) error {
+	if len(diffs) == 0 {
+		fmt.Printf("the database schema matches the %s\n", expectedName)
+		return nil
+	}
+
+	fmt.Printf("found %v difference(s) with the %s:\n", len(diffs), expectedName)
+	for _, diff := range diffs {
+		fmt.Printf("* %s\n", diff)
+	}
+
+	return errDiff
+}
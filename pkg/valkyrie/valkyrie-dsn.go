@@ -0,0 +1,180 @@
+package valkyrie
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// sqliteExtensions are the file extensions of SQLite databases.
+var sqliteExtensions = []string{".db", ".sqlite", ".sqlite3"}
+
+// libpqKeywords are the parameters of libpq key=value connection strings,
+// the first key of a connection string must be one of them.
+var libpqKeywords = map[string]bool{
+	"host":                      true,
+	"hostaddr":                  true,
+	"port":                      true,
+	"dbname":                    true,
+	"user":                      true,
+	"password":                  true,
+	"passfile":                  true,
+	"connect_timeout":           true,
+	"client_encoding":           true,
+	"options":                   true,
+	"application_name":          true,
+	"fallback_application_name": true,
+	"sslmode":                   true,
+	"sslcert":                   true,
+	"sslkey":                    true,
+	"sslrootcert":               true,
+	"sslpassword":               true,
+	"service":                   true,
+	"target_session_attrs":      true,
+	"search_path":               true,
+}
+
+var keyValueRe = regexp.MustCompile(`^\s*([A-Za-z_]+)\s*=`)
+
+// driverAliases are the names accepted for an explicit driver.
+var driverAliases = map[string]string{
+	"postgres":   DriverPostgres,
+	"postgresql": DriverPostgres,
+	"pgx":        DriverPostgres,
+	"sqlite":     DriverSqlite,
+	"sqlite3":    DriverSqlite,
+}
+
+// ResolveDriver returns the driver of the database referenced by connString
+// without connecting to it. It recognizes postgres:// and postgresql:// urls,
+// libpq key=value strings, SQLite file: uris, :memory: and .db, .sqlite and
+// .sqlite3 files.
+func ResolveDriver(connString string) (string, error) {
+	return ResolveDriverWithOverride(connString, "")
+}
+
+// ResolveDriverWithOverride is ResolveDriver, except an explicit driver name
+// takes precedence over the form of connString.
+func ResolveDriverWithOverride(connString string, driver string) (string, error) {
+	if driver != "" {
+		if resolved, ok := driverAliases[strings.ToLower(driver)]; ok {
+			return resolved, nil
+		}
+
+		return "", fmt.Errorf("unsupported driver '%s', expected one of: %s, %s", driver, DriverPostgres, DriverSqlite)
+	}
+
+	conn := strings.TrimSpace(connString)
+	if conn == "" {
+		return "", fmt.Errorf("missing database connection string")
+	}
+
+	if u, err := url.Parse(conn); err == nil {
+		switch strings.ToLower(u.Scheme) {
+		case "postgres", "postgresql":
+			return DriverPostgres, nil
+		case "file":
+			return DriverSqlite, nil
+		}
+	}
+
+	if conn == ":memory:" {
+		return DriverSqlite, nil
+	}
+
+	if match := keyValueRe.FindStringSubmatch(conn); match != nil && libpqKeywords[strings.ToLower(match[1])] {
+		return DriverPostgres, nil
+	}
+
+	// plain paths may have parameters too, like app.db?_foreign_keys=on
+	filePath, _, _ := strings.Cut(conn, "?")
+	if isSqliteFile(filePath) {
+		return DriverSqlite, nil
+	}
+
+	return "", fmt.Errorf("unable to tell the driver of the connection string, expected a postgres url, a key=value postgres connection string or a %s file. Set the driver explicitly otherwise", strings.Join(sqliteExtensions, ", "))
+}
+
+func isSqliteFile(filePath string) bool {
+	ext := strings.ToLower(path.Ext(filePath))
+	return helpers.Any(sqliteExtensions, func(sqliteExt string) bool { return ext == sqliteExt })
+}
+
+var (
+	journalModes      = []string{"delete", "truncate", "persist", "memory", "wal", "off"}
+	synchronousModes  = []string{"off", "normal", "full", "extra"}
+	foreignKeysValues = []string{"on", "off"}
+)
+
+// sqlitePragmaParams are the connection string parameters of the sqlite3
+// driver setting each pragma, with the aliases the driver prefers over them.
+var sqlitePragmaParams = map[string][]string{
+	"foreign_keys": {"_foreign_keys", "_fk"},
+	"journal_mode": {"_journal_mode", "_journal"},
+	"synchronous":  {"_synchronous", "_sync"},
+	"busy_timeout": {"_busy_timeout", "_timeout"},
+}
+
+// ApplySqlitePragmas adds the pragmas to a SQLite connection string, so the
+// driver sets them on every connection it opens. They replace the ones the
+// connection string already sets, and can't be set on other drivers.
+func ApplySqlitePragmas(connString string, driver string, pragmas models.SqlitePragmas) (string, error) {
+	if pragmas.IsZero() {
+		return connString, nil
+	}
+
+	driver, err := ResolveDriverWithOverride(connString, driver)
+	if err != nil {
+		return "", err
+	} else if driver != DriverSqlite {
+		return "", fmt.Errorf("pragmas can only be set on %s databases, not %s", DriverSqlite, driver)
+	}
+
+	values := make(map[string]string)
+	if values["foreign_keys"], err = pragmaValue("foreign_keys", pragmas.ForeignKeys, foreignKeysValues); err != nil {
+		return "", err
+	}
+	if values["journal_mode"], err = pragmaValue("journal_mode", pragmas.JournalMode, journalModes); err != nil {
+		return "", err
+	}
+	if values["synchronous"], err = pragmaValue("synchronous", pragmas.Synchronous, synchronousModes); err != nil {
+		return "", err
+	}
+	if pragmas.BusyTimeout != 0 {
+		values["busy_timeout"] = strconv.FormatInt(pragmas.BusyTimeout.Milliseconds(), 10)
+	}
+
+	dsn, query, _ := strings.Cut(connString, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid connection string parameters: %v", err)
+	}
+
+	for pragma, value := range values {
+		if value == "" {
+			continue
+		}
+
+		for _, param := range sqlitePragmaParams[pragma] {
+			params.Del(param)
+		}
+		params.Set(sqlitePragmaParams[pragma][0], value)
+	}
+
+	return dsn + "?" + params.Encode(), nil
+}
+
+func pragmaValue(pragma string, value string, allowed []string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || helpers.Any(allowed, func(v string) bool { return v == value }) {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("invalid %s '%s', expected one of: %s", pragma, value, strings.Join(allowed, ", "))
+}
@@ -0,0 +1,32 @@
+package valkyrie
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+// IntrospectSchema reads the normalized schema of the database, excluding the
+// migration tracking tables.
+func IntrospectSchema(db *sql.DB, driver string) (*schema.Schema, error) {
+	switch driver {
+	case DriverPostgres:
+		return schema.IntrospectPostgres(db)
+	case DriverSqlite:
+		return schema.IntrospectSqlite(db)
+	}
+
+	return nil, fmt.Errorf("unsupported driver '%s', expected one of: %s, %s", driver, DriverPostgres, DriverSqlite)
+}
+
+// Dump writes a deterministic snapshot of the database schema.
+func Dump(db *sql.DB, driver string, out io.Writer) error {
+	s, err := IntrospectSchema(db, driver)
+	if err != nil {
+		return err
+	}
+
+	return s.Write(out)
+}
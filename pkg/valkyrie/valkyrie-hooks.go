@@ -0,0 +1,158 @@
+package valkyrie
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// WithHookCommands runs shell commands before and after the run, every group
+// and every file. Group and file commands run while the transaction is open,
+// run commands outside of it. A retried run rolls its transaction back, so
+// group and file commands run again on every attempt, run commands once.
+func WithHookCommands(commands models.HookCommands) MigrateOption {
+	return func(app *MigrateApp) {
+		app.hookCommands = commands
+	}
+}
+
+// hookRunner runs the sql hook files of a migration folder and the configured
+// shell commands around the migrations.
+type hookRunner struct {
+	// ctx is the context of the run, hooks stop with it.
+	ctx      context.Context
+	fsys     fs.FS
+	commands models.HookCommands
+	// hookSql caches the content of every hook file path, empty when missing.
+	hookSql map[string]string
+}
+
+func newHookRunner(ctx context.Context, fsys fs.FS, commands models.HookCommands) *hookRunner {
+	return &hookRunner{
+		ctx:      ctx,
+		fsys:     fsys,
+		commands: commands,
+		hookSql:  make(map[string]string),
+	}
+}
+
+func (h *hookRunner) BeforeRun(tx *sql.Tx) error {
+	return h.execSql(tx, migrations.BeforeHook)
+}
+
+func (h *hookRunner) AfterRun(tx *sql.Tx) error {
+	return h.execSql(tx, migrations.AfterHook)
+}
+
+func (h *hookRunner) BeforeGroup(tx *sql.Tx, group *models.MigrationGroup) error {
+	if err := h.runCommands("before group", h.commands.BeforeGroup, group.Name, ""); err != nil {
+		return err
+	}
+
+	return h.execSql(tx, path.Join(group.Name, migrations.BeforeHook))
+}
+
+func (h *hookRunner) AfterGroup(tx *sql.Tx, group *models.MigrationGroup) error {
+	if err := h.execSql(tx, path.Join(group.Name, migrations.AfterHook)); err != nil {
+		return err
+	}
+
+	return h.runCommands("after group", h.commands.AfterGroup, group.Name, "")
+}
+
+func (h *hookRunner) BeforeFile(tx *sql.Tx, mig *models.Migration) error {
+	if err := h.runCommands("before file", h.commands.BeforeFile, mig.GroupName, mig.Name); err != nil {
+		return err
+	}
+
+	if err := h.execSql(tx, migrations.BeforeEachHook); err != nil {
+		return err
+	}
+
+	return h.execSql(tx, path.Join(mig.GroupName, migrations.BeforeEachHook))
+}
+
+func (h *hookRunner) AfterFile(tx *sql.Tx, mig *models.Migration, duration time.Duration) error {
+	if err := h.execSql(tx, path.Join(mig.GroupName, migrations.AfterEachHook)); err != nil {
+		return err
+	}
+
+	if err := h.execSql(tx, migrations.AfterEachHook); err != nil {
+		return err
+	}
+
+	return h.runCommands("after file", h.commands.AfterFile, mig.GroupName, mig.Name)
+}
+
+func (h *hookRunner) FileFailed(mig *models.Migration, err error) {}
+
+// readSql returns the content of the hook file at hookPath, empty when the
+// migration folder doesn't have it.
+func (h *hookRunner) readSql(hookPath string) (string, error) {
+	hookSql, ok := h.hookSql[hookPath]
+	if !ok {
+		buf, err := fs.ReadFile(h.fsys, hookPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		hookSql = string(buf)
+		h.hookSql[hookPath] = hookSql
+	}
+
+	return hookSql, nil
+}
+
+// execSql runs the hook file at hookPath, if the migration folder has it.
+func (h *hookRunner) execSql(tx *sql.Tx, hookPath string) error {
+	hookSql, err := h.readSql(hookPath)
+	if err != nil {
+		return err
+	} else if hookSql == "" {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(h.ctx, hookSql); err != nil {
+		return fmt.Errorf("hook %s failed: %w", hookPath, err)
+	}
+
+	fmt.Printf("\t * hook %s\n", hookPath)
+	return nil
+}
+
+// runCommands runs shell commands, exposing the hook, group and file they run
+// for as VALKYRIE_HOOK, VALKYRIE_GROUP and VALKYRIE_FILE.
+func (h *hookRunner) runCommands(hook string, commands []string, groupName string, fileName string) error {
+	for _, command := range commands {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(h.ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(h.ctx, "sh", "-c", command)
+		}
+
+		cmd.Env = append(os.Environ(),
+			"VALKYRIE_HOOK="+hook,
+			"VALKYRIE_GROUP="+groupName,
+			"VALKYRIE_FILE="+fileName,
+		)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook '%s' failed: %v", hook, command, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package valkyrie_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	connString := path.Join(dir, "hooks.db")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	writeMigration(t, migrationFolder, "Users", "20240102_cr_orgs.sql", "CREATE TABLE orgs (id INTEGER PRIMARY KEY);")
+	writeMigration(t, migrationFolder, "Users", "_before.sql", "INSERT INTO hook_log (hook) VALUES ('before group');")
+	writeMigration(t, migrationFolder, "Users", "_after_each.sql", "INSERT INTO hook_log (hook) VALUES ('after file');")
+
+	if err := os.WriteFile(path.Join(migrationFolder, "_before.sql"), []byte("CREATE TABLE hook_log (id INTEGER PRIMARY KEY, hook TEXT);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(migrationFolder, "_after.sql"), []byte("INSERT INTO hook_log (hook) VALUES ('after run');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := valkyrie.NewMigrationStorer(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := helpers.GetDb(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT hook FROM hook_log ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	hooks := make([]string, 0)
+	for rows.Next() {
+		var hook string
+		if err := rows.Scan(&hook); err != nil {
+			t.Fatal(err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	expectedHooks := []string{"before group", "after file", "after file", "after run"}
+	if len(hooks) != len(expectedHooks) {
+		t.Fatalf("expected '%v', got '%v'", expectedHooks, hooks)
+	}
+	for i, hook := range hooks {
+		if hook != expectedHooks[i] {
+			t.Errorf("expected '%v', got '%v'", expectedHooks[i], hook)
+		}
+	}
+
+	var migrationCount int
+	if err := db.QueryRow("SELECT count(*) FROM migration").Scan(&migrationCount); err != nil {
+		t.Fatal(err)
+	}
+	if migrationCount != 2 {
+		t.Errorf("expected '%v', got '%v'", 2, migrationCount)
+	}
+}
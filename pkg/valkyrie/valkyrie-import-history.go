@@ -0,0 +1,73 @@
+package valkyrie
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/importer"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// ImportHistory reads the tracking table of another tool from sourceDb and
+// marks the migrations it applied as applied in valkyrie, using the mapping
+// report written by Import. from defaults to the tool recorded in the mapping.
+// Migrations already logged are skipped, so it's safe to run again.
+func ImportHistory(repo models.MigrationStorer, sourceDb *sql.DB, from string, mappingPath string) error {
+	mapping, err := importer.ReadMapping(mappingPath)
+	if err != nil {
+		return err
+	}
+
+	if from == "" {
+		from = mapping.From
+	} else if mapping.From != "" && mapping.From != from {
+		return fmt.Errorf("mapping file was generated from %s, not %s", mapping.From, from)
+	}
+
+	versions, err := importer.ReadAppliedVersions(sourceDb, from, mapping)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.EnsureCreated(); err != nil {
+		fmt.Println("failed to create migration tables")
+		return err
+	}
+
+	marks := make([]models.AppliedMark, 0, len(versions))
+	sourceVersions := make(map[string]string, len(versions))
+	unmapped := make([]string, 0)
+
+	for _, version := range versions {
+		mig := mapping.FindByVersion(version)
+		if mig == nil {
+			unmapped = append(unmapped, version)
+			continue
+		}
+
+		marks = append(marks, models.AppliedMark{
+			GroupName:     mig.Group,
+			MigrationName: mig.Name,
+			Reason:        fmt.Sprintf("imported from %s version %s", from, version),
+		})
+		sourceVersions[mig.Group+"/"+mig.Name] = version
+	}
+
+	// a single transaction, so a failed import leaves the log untouched
+	logged, err := repo.MarkAllApplied(marks)
+	if err != nil {
+		return fmt.Errorf("failed to import the applied versions of %s: %v", from, err)
+	}
+
+	for _, mark := range logged {
+		fmt.Printf("\t * %s -> %s/%s\n", sourceVersions[mark.GroupName+"/"+mark.MigrationName], mark.GroupName, mark.MigrationName)
+	}
+
+	fmt.Printf("imported %v applied migrations from %s, %v were already logged\n", len(logged), from, len(marks)-len(logged))
+
+	if len(unmapped) > 0 {
+		fmt.Printf("warning: %v applied versions have no mapped migration: %v\n", len(unmapped), unmapped)
+	}
+
+	return nil
+}
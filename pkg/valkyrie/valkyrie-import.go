@@ -0,0 +1,100 @@
+package valkyrie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/importer"
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+)
+
+// ImportOptions configures how the migrations of another tool are converted.
+type ImportOptions struct {
+	// Group the converted migrations are written to. Defaults to the name of the source folder.
+	Group string
+	// BaseDate (yyyymmdd) used for sequentially versioned migrations. Defaults to today.
+	BaseDate string
+	// ReportPath, when set, is where the json mapping report is written.
+	ReportPath string
+}
+
+// Import converts the migrations in srcDir, laid out for golang-migrate, goose
+// or flyway, into a valkyrie group inside migrationFolder.
+func Import(from string, srcDir string, migrationFolder string, opts ImportOptions) error {
+	group := opts.Group
+	if group == "" {
+		group = filepath.Base(filepath.Clean(srcDir))
+	}
+
+	if strings.ContainsAny(group, `/\`) {
+		return fmt.Errorf("(%s): group name may not contain path separators", group)
+	}
+
+	baseDate := opts.BaseDate
+	if baseDate == "" {
+		baseDate = time.Now().Format("20060102")
+	} else if err := migrations.CheckDate(baseDate); err != nil {
+		return fmt.Errorf("(%s): base date doesn't match expected format (yyyymmdd)", baseDate)
+	}
+
+	mapping, err := importer.Convert(from, srcDir, group, baseDate)
+	if err != nil {
+		return err
+	}
+
+	groupPath := path.Join(migrationFolder, group)
+
+	// check every target first so a conflict doesn't leave a half imported group
+	for _, mig := range mapping.Migrations {
+		if _, err := os.Stat(path.Join(groupPath, mig.Name)); err == nil {
+			return fmt.Errorf("(%s): migration already exists in group '%s'", mig.Name, group)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(groupPath, 0755); err != nil {
+		return err
+	}
+
+	for _, mig := range mapping.Migrations {
+		if err := os.WriteFile(path.Join(groupPath, mig.Name), mig.Content, 0644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("imported %v migrations from %s into group '%s':\n", len(mapping.Migrations), from, group)
+	for _, mig := range mapping.Migrations {
+		fmt.Printf("\t%s -> %s/%s\n", mig.Source, mig.Group, mig.Name)
+	}
+
+	if len(mapping.Skipped) > 0 {
+		fmt.Printf("skipped %v files:\n", len(mapping.Skipped))
+		for _, skipped := range mapping.Skipped {
+			fmt.Printf("\t%s: %s\n", skipped.Source, skipped.Reason)
+		}
+	}
+
+	for _, warning := range mapping.Warnings {
+		fmt.Printf("warning: %s: %s\n", warning.Source, warning.Message)
+	}
+
+	if opts.ReportPath == "" {
+		return nil
+	}
+
+	buf, err := json.MarshalIndent(mapping, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("writing mapping report to %s\n", opts.ReportPath)
+	return os.WriteFile(opts.ReportPath, buf, 0644)
+}
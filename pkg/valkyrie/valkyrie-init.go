@@ -3,32 +3,108 @@ package valkyrie
 import (
 	"database/sql"
 	"fmt"
-	"path"
-	"strings"
 
-	"github.com/marianop9/valkyrie-migrate/internal/helpers"
-	"github.com/marianop9/valkyrie-migrate/internal/repository"
-	postgresRepo "github.com/marianop9/valkyrie-migrate/internal/repository/postgres"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
 )
 
-func Init(dbName string) error {
+// InitOptions configure how Init opens and creates the database.
+type InitOptions struct {
+	// Driver is resolved from the connection string when empty.
+	Driver string
+	// Pragmas are set on SQLite connections, the journal mode is persisted
+	// in the database file.
+	Pragmas models.SqlitePragmas
+	// PageSize sets the page size of a new SQLite database, zero keeps the
+	// default.
+	PageSize int
+}
+
+// Init creates the migration tables of the database referenced by connString,
+// creating SQLite databases that don't exist yet.
+func Init(connString string) error {
+	return InitWithOptions(connString, InitOptions{})
+}
+
+// InitWithDriver is Init with an explicit driver, resolved from connString
+// when empty.
+func InitWithDriver(connString string, driver string) error {
+	return InitWithOptions(connString, InitOptions{Driver: driver})
+}
+
+// InitWithOptions is Init for the given options.
+func InitWithOptions(connString string, opts InitOptions) error {
+	if opts.PageSize != 0 && !validPageSize(opts.PageSize) {
+		return fmt.Errorf("invalid page size %v, expected a power of two between 512 and 65536", opts.PageSize)
+	}
+
+	// the journal mode is set after the page size, as switching to WAL
+	// writes the database header and fixes its page size
+	journalMode, err := pragmaValue("journal_mode", opts.Pragmas.JournalMode, journalModes)
+	if err != nil {
+		return err
+	}
+
+	pragmas := opts.Pragmas
+	pragmas.JournalMode = ""
+
+	if connString, err = ApplySqlitePragmas(connString, opts.Driver, pragmas); err != nil {
+		return err
+	}
+
+	db, driver, err := OpenDbWithDriver(connString, opts.Driver)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	var db *sql.DB
-	var err error
+	if driver == DriverPostgres && (opts.PageSize != 0 || journalMode != "") {
+		return fmt.Errorf("the page size and journal mode can only be set on %s databases", DriverSqlite)
+	}
+
+	if driver == DriverSqlite {
+		// a single connection, so the pragmas apply to the one creating the file
+		db.SetMaxOpenConns(1)
 
-	if strings.HasPrefix(dbName, "postgresql://") {
-		if db, err = helpers.GetPostgresDb(dbName); err != nil {
+		if err := setPageSize(db, opts.PageSize); err != nil {
 			return err
 		}
 
-		return postgresRepo.NewMigrationRepo(db).EnsureCreated()
-	} else if path.Ext(dbName) == ".db" {
-		if db, err = helpers.GetDb(dbName); err != nil {
-			return err
+		if journalMode != "" {
+			if _, err := db.Exec("PRAGMA journal_mode = " + journalMode); err != nil {
+				return fmt.Errorf("failed to set journal mode: %v", err)
+			}
 		}
+	}
+
+	return NewMigrationStorerForDb(db, driver).EnsureCreated()
+}
+
+// setPageSize sets the page size of a database without pages yet, existing
+// databases must already use it.
+func setPageSize(db *sql.DB, pageSize int) error {
+	if pageSize == 0 {
+		return nil
+	}
 
-		return repository.EnsureCreated(db)
+	var pageCount, currentSize int
+	if err := db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return err
+	}
+	if err := db.QueryRow("PRAGMA page_size").Scan(&currentSize); err != nil {
+		return err
 	}
 
-	return fmt.Errorf("invalid database file extension")
+	if pageCount > 0 {
+		if currentSize != pageSize {
+			return fmt.Errorf("the page size can only be set when creating a database, this one uses %v", currentSize)
+		}
+		return nil
+	}
+
+	_, err := db.Exec(fmt.Sprintf("PRAGMA page_size = %d", pageSize))
+	return err
+}
+
+func validPageSize(pageSize int) bool {
+	return pageSize >= 512 && pageSize <= 65536 && pageSize&(pageSize-1) == 0
 }
@@ -0,0 +1,116 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/marianop9/valkyrie-migrate/internal/lint"
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+var ErrLintFailed = errors.New("migrations have lint findings at or above the failing severity")
+
+type LintOptions struct {
+	// Dialect skips the rules of the other dialect, every rule runs when empty.
+	Dialect string
+	// Severities override the severity of rules by name: off, info, warning or error.
+	Severities map[string]string
+	// FailOn is the lowest severity that fails the run, error when empty.
+	FailOn  string
+	Include []string
+	Exclude []string
+}
+
+// Lint checks the migrations of the folder for risky statements, printing
+// every finding. Down scripts and hook files aren't checked.
+func Lint(migrationFolder string, opts LintOptions) error {
+	config, failOn, err := opts.config()
+	if err != nil {
+		return err
+	}
+
+	fsys, err := migrationFS(migrationFolder)
+	if err != nil {
+		return err
+	}
+
+	groups, err := readMigrationGroups(migrationFolder, migrations.GroupFilter{
+		Include: opts.Include,
+		Exclude: opts.Exclude,
+	})
+	if err != nil {
+		return err
+	}
+
+	fileCount := 0
+	findings := make([]lint.Finding, 0)
+
+	for _, group := range groups {
+		files := make([]models.Migration, 0, len(group.Migrations)+len(group.Repeatables))
+		files = append(files, group.Migrations...)
+		files = append(files, group.Repeatables...)
+
+		for _, mig := range files {
+			filePath := path.Join(group.Name, mig.Name)
+
+			buf, err := fs.ReadFile(fsys, filePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file %v: %v", filePath, err)
+			}
+
+			findings = append(findings, lint.Lint(filePath, string(buf), config)...)
+			fileCount++
+		}
+	}
+
+	failed := 0
+	for _, finding := range findings {
+		fmt.Println(finding)
+
+		if finding.Severity >= failOn {
+			failed++
+		}
+	}
+
+	fmt.Printf("%v files checked, %v finding(s), %v at or above %s\n", fileCount, len(findings), failed, failOn)
+
+	if failed > 0 {
+		return ErrLintFailed
+	}
+
+	return nil
+}
+
+func (opts LintOptions) config() (lint.Config, lint.Severity, error) {
+	config := lint.Config{
+		Dialect:    opts.Dialect,
+		Severities: make(map[string]lint.Severity, len(opts.Severities)),
+	}
+
+	for rule, name := range opts.Severities {
+		severity, err := lint.ParseSeverity(name)
+		if err != nil {
+			return config, lint.SeverityOff, fmt.Errorf("rule '%s': %v", rule, err)
+		}
+		config.Severities[rule] = severity
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, lint.SeverityOff, err
+	}
+
+	failOn := lint.SeverityError
+	if opts.FailOn != "" {
+		var err error
+		if failOn, err = lint.ParseSeverity(opts.FailOn); err != nil {
+			return config, lint.SeverityOff, err
+		} else if failOn == lint.SeverityOff {
+			return config, lint.SeverityOff, fmt.Errorf("the failing severity can't be off")
+		}
+	}
+
+	return config, failOn, nil
+}
@@ -0,0 +1,47 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+var ErrNoMarkReason = errors.New("a reason must be given when editing the migration history")
+
+// Mark edits the migration history without executing any sql. The migration is
+// referenced as '<group>/<file>' and status is either models.MarkStatusApplied
+// or models.MarkStatusPending.
+func Mark(repo models.MigrationStorer, migrationRef, status, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return ErrNoMarkReason
+	}
+
+	groupName, migrationName, err := migrations.ParseMigrationRef(migrationRef)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.EnsureCreated(); err != nil {
+		fmt.Println("failed to create migration tables")
+		return err
+	}
+
+	switch status {
+	case models.MarkStatusApplied:
+		err = repo.MarkApplied(groupName, migrationName, reason)
+	case models.MarkStatusPending:
+		err = repo.MarkPending(groupName, migrationName, reason)
+	default:
+		return fmt.Errorf("invalid migration status '%s'", status)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("marked %s/%s as %s\n", groupName, migrationName, status)
+	return nil
+}
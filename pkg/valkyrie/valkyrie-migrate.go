@@ -1,12 +1,17 @@
 package valkyrie
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path"
+	"time"
 
 	"github.com/marianop9/valkyrie-migrate/internal/helpers"
 	"github.com/marianop9/valkyrie-migrate/internal/migrations"
@@ -16,15 +21,86 @@ import (
 
 type MigrateApp struct {
 	//	repo *sqliteRepo.SqliteRepo
-	repo models.MigrationStorer
+	repo             models.MigrationStorer
+	outOfOrderPolicy OutOfOrderPolicy
+	dryRun           bool
+	target           *MigrationTarget
+	groupFilter      migrations.GroupFilter
+	hookCommands     models.HookCommands
+	observer         RunObserver
+	timeouts         models.Timeouts
+	runTimeout       time.Duration
+	retryPolicy      RetryPolicy
 }
 
-func NewMigrateApp(repo models.MigrationStorer) *MigrateApp {
-	return &MigrateApp{
-		repo,
+// MigrateOption configures optional behaviour of a MigrateApp.
+type MigrateOption func(*MigrateApp)
+
+// WithOutOfOrderPolicy sets what happens when pending migrations are dated
+// before migrations that were already applied.
+func WithOutOfOrderPolicy(policy OutOfOrderPolicy) MigrateOption {
+	return func(app *MigrateApp) {
+		app.outOfOrderPolicy = policy
+	}
+}
+
+// WithDryRun makes Run print the migration plan without executing it or
+// creating the tracking tables.
+func WithDryRun(dryRun bool) MigrateOption {
+	return func(app *MigrateApp) {
+		app.dryRun = dryRun
+	}
+}
+
+// WithTarget stops the run at the given point of the plan instead of applying
+// every pending migration. A nil target runs up to the latest migration.
+func WithTarget(target *MigrationTarget) MigrateOption {
+	return func(app *MigrateApp) {
+		app.target = target
+	}
+}
+
+// WithGroups only considers the migration groups matching the include glob
+// patterns and none of the exclude ones. Empty include patterns match every group.
+func WithGroups(include []string, exclude []string) MigrateOption {
+	return func(app *MigrateApp) {
+		app.groupFilter = migrations.GroupFilter{
+			Include: include,
+			Exclude: exclude,
+		}
+	}
+}
+
+// WithTimeouts limits how long every statement may run and wait for locks,
+// unless a file's header overrides them. Zero values set no limit.
+func WithTimeouts(timeouts models.Timeouts) MigrateOption {
+	return func(app *MigrateApp) {
+		app.timeouts = timeouts
+	}
+}
+
+// WithRunTimeout cancels the run, rolling its transaction back, when applying
+// the migrations takes longer than timeout. Zero sets no limit.
+func WithRunTimeout(timeout time.Duration) MigrateOption {
+	return func(app *MigrateApp) {
+		app.runTimeout = timeout
 	}
 }
 
+func NewMigrateApp(repo models.MigrationStorer, opts ...MigrateOption) *MigrateApp {
+	app := &MigrateApp{
+		repo:             repo,
+		outOfOrderPolicy: OutOfOrderWarn,
+		observer:         NopObserver{},
+	}
+
+	for _, opt := range opts {
+		opt(app)
+	}
+
+	return app
+}
+
 // Creates a new migration instance connected to the specified database
 func NewMigration(db *sql.DB, dbDriver string) *MigrateApp {
 	repo := sqliteRepo.NewMigrationRepo(db)
@@ -32,44 +108,407 @@ func NewMigration(db *sql.DB, dbDriver string) *MigrateApp {
 }
 
 func (app MigrateApp) Run(migrationFolder string) error {
-	// get migrations directory
-	dirEntries, err := os.ReadDir(migrationFolder)
+	fsys, err := migrationFS(migrationFolder)
+	if err != nil {
+		return err
+	}
+
+	return app.RunFS(fsys)
+}
 
+// RunFS applies the pending migrations of the migration groups at the root of
+// fsys, like an embed.FS of the migration folder.
+func (app MigrateApp) RunFS(fsys fs.FS) error {
+	plan, err := app.planFS(fsys, app.dryRun)
 	if err != nil {
 		return err
 	}
 
-	if len(dirEntries) == 0 {
-		return fmt.Errorf("no migrations found in folder: %+v", migrationFolder)
+	start := time.Now()
+	app.observer.OnRunStart(plan)
+
+	applied, err := app.execute(fsys, plan)
+
+	app.observer.OnRunEnd(RunResult{
+		Applied:  applied,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+
+	return err
+}
+
+// execute applies the pending groups of the plan, returning how many
+// migrations were committed.
+func (app MigrateApp) execute(fsys fs.FS, plan *MigrationPlan) (int, error) {
+	if len(plan.Groups) == 0 {
+		fmt.Println("no migration groups found")
+		return 0, nil
+	} else if len(plan.Pending) == 0 {
+		fmt.Println("database is up to date. Exiting...")
+		return 0, nil
 	}
-	fmt.Println("found groups: ", len(dirEntries))
 
-	if err := checkMigrationSubfolders(dirEntries); err != nil {
-		return err
+	plan.Print()
+
+	if err := app.checkOutOfOrder(plan); err != nil {
+		return 0, err
 	}
 
-	if err := app.repo.EnsureCreated(); err != nil {
-		fmt.Println("failed to create migration tables")
-		return err
+	if app.dryRun {
+		fmt.Println("dry run, no migrations were executed")
+		return 0, nil
 	}
 
-	// retrieve migrations from folder
-	migrationGroups, err := migrations.GetMigrationGroups(migrationFolder, dirEntries)
+	ctx := context.Background()
+	if app.runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, app.runTimeout)
+		defer cancel()
+	}
+
+	hooks := newHookRunner(ctx, fsys, app.hookCommands)
+
+	if err := hooks.runCommands("before run", app.hookCommands.BeforeRun, "", ""); err != nil {
+		return 0, err
+	}
+
+	attempts := 0
+	migrationCount, err := app.retry(ctx, func() (int, error) {
+		attempts++
+		if attempts == 1 {
+			return app.executeAttempt(ctx, fsys, plan.Pending, hooks)
+		}
+
+		// a connection lost while committing doesn't say whether the
+		// commit went through, so retries skip what's logged as applied
+		pending, err := app.stillPending(plan.Pending)
+		if err != nil {
+			return 0, err
+		} else if len(pending) == 0 {
+			fmt.Println("an earlier attempt committed every pending migration")
+			return countMigrations(plan.Pending), nil
+		}
 
+		return app.executeAttempt(ctx, fsys, pending, hooks)
+	})
 	if err != nil {
-		return err
-	} else if len(migrationGroups) == 0 {
-		fmt.Println("no migration groups found")
-		return nil
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 0, fmt.Errorf("migration run timed out after %v, no migrations were applied: %w", app.runTimeout, err)
+		}
+		return 0, err
+	}
+
+	// after the commit, so the commands see the migrated database
+	return migrationCount, hooks.runCommands("after run", app.hookCommands.AfterRun, "", "")
+}
+
+// executeAttempt runs the pending groups in one transaction. The groups are
+// copied and their files read again, so a failed attempt leaves nothing
+// behind for the next one.
+func (app MigrateApp) executeAttempt(ctx context.Context, fsys fs.FS, pending []*models.MigrationGroup, hooks *hookRunner) (int, error) {
+	migrationGroupsToApply := make([]*models.MigrationGroup, len(pending))
+	migrationCount := 0
+
+	for i, group := range pending {
+		groupToApply := *group
+		migrationGroupsToApply[i] = &groupToApply
+
+		if err := app.readMigrations(fsys, groupToApply.Name, groupToApply.Migrations); err != nil {
+			return 0, err
+		}
+		migrationCount += len(groupToApply.Migrations)
+
+		if err := app.readMigrations(fsys, groupToApply.Name, groupToApply.Repeatables); err != nil {
+			return 0, err
+		}
+		migrationCount += len(groupToApply.Repeatables)
+	}
+
+	observed := observedHooks{ExecutionHooks: hooks, observer: app.observer}
+	if err := app.repo.ExecuteMigrations(ctx, migrationGroupsToApply, observed); err != nil {
+		return 0, err
+	}
+
+	return migrationCount, nil
+}
+
+// stillPending returns the groups of pending without the migrations the
+// database logs as applied and the repeatable migrations it logs with the
+// same checksum.
+func (app MigrateApp) stillPending(pending []*models.MigrationGroup) ([]*models.MigrationGroup, error) {
+	applied, err := app.repo.GetMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	checksums, err := app.repo.GetRepeatableChecksums()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*models.MigrationGroup, 0, len(pending))
+	for _, group := range pending {
+		stillPendingGroup := *group
+
+		stillPendingGroup.Migrations = make([]models.Migration, 0, len(group.Migrations))
+		appliedGroup := helpers.FindMigrationGroup(applied, group.Name)
+		for _, mig := range group.Migrations {
+			if appliedGroup == nil || helpers.FindMigration(appliedGroup.Migrations, mig.Name) == nil {
+				stillPendingGroup.Migrations = append(stillPendingGroup.Migrations, mig)
+			}
+		}
+		stillPendingGroup.MigrationCount = len(stillPendingGroup.Migrations)
+
+		// the group may have been created by the earlier attempt
+		if appliedGroup != nil {
+			stillPendingGroup.Id = appliedGroup.Id
+		}
+
+		stillPendingGroup.Repeatables = make([]models.Migration, 0, len(group.Repeatables))
+		for _, mig := range group.Repeatables {
+			if checksums[models.RepeatableKey(group.Name, mig.Name)] != mig.Checksum {
+				stillPendingGroup.Repeatables = append(stillPendingGroup.Repeatables, mig)
+			}
+		}
+
+		if len(stillPendingGroup.Migrations) > 0 || len(stillPendingGroup.Repeatables) > 0 {
+			groups = append(groups, &stillPendingGroup)
+		}
+	}
+
+	return groups, nil
+}
+
+func countMigrations(groups []*models.MigrationGroup) int {
+	count := 0
+	for _, group := range groups {
+		count += len(group.Migrations) + len(group.Repeatables)
+	}
+
+	return count
+}
+
+// readMigrations reads the files of the migrations, so the repository can
+// execute them, with the timeouts and pragmas of their headers.
+func (app MigrateApp) readMigrations(fsys fs.FS, groupName string, migs []models.Migration) error {
+	for i := range migs {
+		mig := &migs[i]
+
+		buf, err := fs.ReadFile(fsys, path.Join(groupName, mig.Name))
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read file %v", mig.Name), err)
+		}
+
+		if mig.Timeouts, err = migrations.ParseTimeouts(buf, app.timeouts); err != nil {
+			return fmt.Errorf("(%s/%s): %v", groupName, mig.Name, err)
+		}
+		if mig.Pragmas, err = migrations.ParsePragmas(buf); err != nil {
+			return fmt.Errorf("(%s/%s): %v", groupName, mig.Name, err)
+		}
+		mig.FReader = bytes.NewReader(buf)
+	}
+
+	return nil
+}
+
+// Plan compares the migration folder with the migrations logged in the
+// database and returns the groups that still need to be applied.
+func (app MigrateApp) Plan(migrationFolder string) (*MigrationPlan, error) {
+	fsys, err := migrationFS(migrationFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.PlanFS(fsys)
+}
+
+// PlanFS is Plan for the migration groups at the root of fsys.
+func (app MigrateApp) PlanFS(fsys fs.FS) (*MigrationPlan, error) {
+	return app.planFS(fsys, false)
+}
+
+// planFS computes the plan of fsys. A read only plan doesn't create the
+// tracking tables, missing ones are taken as nothing applied.
+func (app MigrateApp) planFS(fsys fs.FS, readOnly bool) (*MigrationPlan, error) {
+	// retrieve migrations from folder
+	migrationGroups, err := readMigrationGroupsFS(fsys, app.groupFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	missingTables := []string{}
+	if readOnly {
+		if missingTables, err = app.repo.MissingTables(); err != nil {
+			return nil, errors.Join(errors.New("failed to find the migration tables"), err)
+		}
+	} else if err := app.repo.EnsureCreated(); err != nil {
+		fmt.Println("failed to create migration tables")
+		return nil, err
+	}
+
+	isMissing := func(table string) bool {
+		return helpers.Any(missingTables, func(missing string) bool { return missing == table })
 	}
 
 	// retrieve db migrations
-	existingMigrations, err := app.repo.GetMigrations()
+	existingMigrations := []models.MigrationGroup{}
+	if !isMissing("migration_group") && !isMissing("migration") {
+		if existingMigrations, err = app.repo.GetMigrations(); err != nil {
+			return nil, errors.Join(errors.New("failed to retrieve migrations from db"), err)
+		}
+	}
+
+	plan := &MigrationPlan{
+		Groups:  migrationGroups,
+		Applied: existingMigrations,
+		Pending: findPendingGroups(migrationGroups, existingMigrations),
+	}
+
+	if app.target != nil {
+		if plan.Pending, err = app.target.apply(plan.Pending); err != nil {
+			return nil, err
+		}
+		fmt.Printf("migrating up to %s\n", app.target)
+	}
+
+	plan.OutOfOrder = findOutOfOrder(plan.Pending, existingMigrations)
+
+	// repeatable migrations may depend on any versioned one, so they only
+	// run when migrating to the latest migration
+	var checksums map[string]string
+	if app.target == nil && isMissing("repeatable_migration") {
+		checksums = map[string]string{}
+	} else if app.target == nil {
+		if checksums, err = app.repo.GetRepeatableChecksums(); err != nil {
+			return nil, errors.Join(errors.New("failed to retrieve repeatable migrations from db"), err)
+		}
+	}
+
+	if err := setPendingRepeatables(fsys, plan, checksums); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// setPendingRepeatables sets the repeatable migrations of the pending groups
+// to the ones whose checksum changed since they last ran, adding the groups
+// that only have repeatable migrations to run. A nil checksums runs none.
+func setPendingRepeatables(fsys fs.FS, plan *MigrationPlan, checksums map[string]string) error {
+	// pending groups may be the ones read from the folder, which list every
+	// repeatable migration
+	pending := make([]*models.MigrationGroup, len(plan.Pending))
+	for i, group := range plan.Pending {
+		pendingGroup := *group
+		pendingGroup.Repeatables = nil
+		pending[i] = &pendingGroup
+	}
+	plan.Pending = pending
+
+	if checksums == nil {
+		return nil
+	}
+
+	for _, group := range plan.Groups {
+		changed := make([]models.Migration, 0)
+
+		for _, mig := range group.Repeatables {
+			buf, err := fs.ReadFile(fsys, path.Join(group.Name, mig.Name))
+			if err != nil {
+				return fmt.Errorf("failed to read file %v: %v", mig.Name, err)
+			}
+
+			sum := sha256.Sum256(buf)
+			mig.Checksum = hex.EncodeToString(sum[:])
+
+			if checksums[models.RepeatableKey(group.Name, mig.Name)] != mig.Checksum {
+				changed = append(changed, mig)
+			}
+		}
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		pendingGroup := findPlanGroup(plan, group.Name)
+		if pendingGroup == nil {
+			pendingGroup = &models.MigrationGroup{
+				Name:       group.Name,
+				Migrations: []models.Migration{},
+			}
+			if applied := helpers.FindMigrationGroup(plan.Applied, group.Name); applied != nil {
+				pendingGroup.Id = applied.Id
+			}
+			plan.Pending = append(plan.Pending, pendingGroup)
+		}
+		pendingGroup.Repeatables = changed
+	}
+
+	return nil
+}
+
+// migrationFS opens the migration folder, failing early when it doesn't exist.
+func migrationFS(migrationFolder string) (fs.FS, error) {
+	if _, err := os.Stat(migrationFolder); err != nil {
+		return nil, err
+	}
+
+	return os.DirFS(migrationFolder), nil
+}
+
+func readMigrationGroups(migrationFolder string, filter migrations.GroupFilter) ([]*models.MigrationGroup, error) {
+	fsys, err := migrationFS(migrationFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := readMigrationGroupsFS(fsys, filter)
+	if errors.Is(err, errNoMigrations) {
+		return nil, fmt.Errorf("%v in folder: %+v", err, migrationFolder)
+	}
+
+	return groups, err
+}
+
+var errNoMigrations = errors.New("no migrations found")
+
+func readMigrationGroupsFS(fsys fs.FS, filter migrations.GroupFilter) ([]*models.MigrationGroup, error) {
+	// get migrations directory
+	dirEntries, err := fs.ReadDir(fsys, ".")
 
 	if err != nil {
-		return errors.Join(errors.New("failed to retrieve migrations from db"), err)
+		return nil, err
 	}
 
+	// run hooks sit next to the groups
+	groupEntries := make([]fs.DirEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		if entry.IsDir() || !migrations.IsHookFile(entry.Name()) {
+			groupEntries = append(groupEntries, entry)
+		}
+	}
+	dirEntries = groupEntries
+
+	if len(dirEntries) == 0 {
+		return nil, errNoMigrations
+	}
+
+	if err := checkMigrationSubfolders(dirEntries); err != nil {
+		return nil, err
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	dirEntries = filter.Apply(dirEntries)
+	fmt.Println("found groups: ", len(dirEntries))
+
+	return migrations.GetMigrationGroupsFS(fsys, dirEntries)
+}
+
+func findPendingGroups(migrationGroups []*models.MigrationGroup, existingMigrations []models.MigrationGroup) []*models.MigrationGroup {
 	// find differences
 	migrationGroupsToApply := make([]*models.MigrationGroup, 0)
 	for _, migrationFolder := range migrationGroups {
@@ -97,38 +536,7 @@ func (app MigrateApp) Run(migrationFolder string) error {
 		}
 	}
 
-	if len(migrationGroupsToApply) == 0 {
-		fmt.Println("database is up to date. Exiting...")
-		return nil
-	}
-
-	fmt.Println("Groups to execute:")
-	for _, group := range migrationGroupsToApply {
-		fmt.Printf("* %s\n", group.Name)
-		fmt.Printf("\t - migrations: %v\n", group.MigrationCount)
-	}
-	fmt.Printf("********\n\n")
-
-	for _, groupToApply := range migrationGroupsToApply {
-		// get the handles for files we need to migrate
-		migrationFolderPath := path.Join(migrationFolder, groupToApply.Name)
-
-		for i := 0; i < len(groupToApply.Migrations); i++ {
-			migration := &groupToApply.Migrations[i]
-
-			fReader, err := os.Open(path.Join(migrationFolderPath, migration.Name))
-			if err != nil {
-				return errors.Join(fmt.Errorf("failed to read file %v", migration.Name), err)
-			}
-			migration.FReader = fReader
-		}
-	}
-
-	if err := app.repo.ExecuteMigrations(migrationGroupsToApply); err != nil {
-		return err
-	}
-
-	return nil
+	return migrationGroupsToApply
 }
 
 func checkMigrationSubfolders(migrationFolderEntries []fs.DirEntry) error {
@@ -0,0 +1,68 @@
+package valkyrie
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// RunObserver receives the progress of MigrateApp.Run, for telemetry or
+// progress reporting. Embed NopObserver to only implement some of the calls.
+type RunObserver interface {
+	// OnRunStart is called once the plan is computed, before anything runs.
+	OnRunStart(plan *MigrationPlan)
+	OnGroupStart(group *models.MigrationGroup)
+	// OnFileApplied is called after a file executes, before the run commits.
+	OnFileApplied(mig *models.Migration, duration time.Duration)
+	// OnError is called when a file fails. Every failure, including the ones
+	// outside of files, is reported by OnRunEnd.
+	OnError(mig *models.Migration, err error)
+	// OnRunEnd is called after every OnRunStart.
+	OnRunEnd(result RunResult)
+}
+
+// RunResult summarizes a run.
+type RunResult struct {
+	// Applied is the number of committed migrations.
+	Applied  int
+	Duration time.Duration
+	Err      error
+}
+
+// NopObserver ignores every call.
+type NopObserver struct{}
+
+func (NopObserver) OnRunStart(plan *MigrationPlan)                              {}
+func (NopObserver) OnGroupStart(group *models.MigrationGroup)                   {}
+func (NopObserver) OnFileApplied(mig *models.Migration, duration time.Duration) {}
+func (NopObserver) OnError(mig *models.Migration, err error)                    {}
+func (NopObserver) OnRunEnd(result RunResult)                                   {}
+
+// WithObserver reports the progress of every run to the observer.
+func WithObserver(observer RunObserver) MigrateOption {
+	return func(app *MigrateApp) {
+		app.observer = observer
+	}
+}
+
+// observedHooks forwards the execution of a run to its observer.
+type observedHooks struct {
+	models.ExecutionHooks
+	observer RunObserver
+}
+
+func (h observedHooks) BeforeGroup(tx *sql.Tx, group *models.MigrationGroup) error {
+	h.observer.OnGroupStart(group)
+	return h.ExecutionHooks.BeforeGroup(tx, group)
+}
+
+func (h observedHooks) AfterFile(tx *sql.Tx, mig *models.Migration, duration time.Duration) error {
+	h.observer.OnFileApplied(mig, duration)
+	return h.ExecutionHooks.AfterFile(tx, mig, duration)
+}
+
+func (h observedHooks) FileFailed(mig *models.Migration, err error) {
+	h.observer.OnError(mig, err)
+	h.ExecutionHooks.FileFailed(mig, err)
+}
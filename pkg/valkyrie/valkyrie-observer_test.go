@@ -0,0 +1,103 @@
+package valkyrie_test
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+type recordingObserver struct {
+	valkyrie.NopObserver
+	events []string
+	result valkyrie.RunResult
+}
+
+func (o *recordingObserver) OnRunStart(plan *valkyrie.MigrationPlan) {
+	o.events = append(o.events, "run start")
+}
+
+func (o *recordingObserver) OnGroupStart(group *models.MigrationGroup) {
+	o.events = append(o.events, "group "+group.Name)
+}
+
+func (o *recordingObserver) OnFileApplied(mig *models.Migration, duration time.Duration) {
+	o.events = append(o.events, "applied "+mig.Name)
+}
+
+func (o *recordingObserver) OnError(mig *models.Migration, err error) {
+	o.events = append(o.events, "error "+mig.Name)
+}
+
+func (o *recordingObserver) OnRunEnd(result valkyrie.RunResult) {
+	o.events = append(o.events, "run end")
+	o.result = result
+}
+
+func TestRunObserver(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		files           map[string]string
+		expectedEvents  []string
+		expectedApplied int
+		expectedErr     bool
+	}{
+		{
+			desc: "successful run",
+			files: map[string]string{
+				"20240101_cr_users.sql": "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"20240102_cr_orgs.sql":  "CREATE TABLE orgs (id INTEGER PRIMARY KEY);",
+			},
+			expectedEvents:  []string{"run start", "group Users", "applied 20240101_cr_users.sql", "applied 20240102_cr_orgs.sql", "run end"},
+			expectedApplied: 2,
+		},
+		{
+			desc: "failing file",
+			files: map[string]string{
+				"20240101_cr_users.sql": "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"20240102_broken.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+			},
+			expectedEvents: []string{"run start", "group Users", "applied 20240101_cr_users.sql", "error 20240102_broken.sql", "run end"},
+			expectedErr:    true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+			for name, sql := range tC.files {
+				writeMigration(t, migrationFolder, "Users", name, sql)
+			}
+
+			repo, err := valkyrie.NewMigrationStorer(path.Join(dir, "observer.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			observer := &recordingObserver{}
+			err = valkyrie.NewMigrateApp(repo, valkyrie.WithObserver(observer)).Run(migrationFolder)
+
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+			}
+
+			if len(observer.events) != len(tC.expectedEvents) {
+				t.Fatalf("expected '%v', got '%v'", tC.expectedEvents, observer.events)
+			}
+			for i, event := range observer.events {
+				if event != tC.expectedEvents[i] {
+					t.Errorf("expected '%v', got '%v'", tC.expectedEvents[i], event)
+				}
+			}
+
+			if observer.result.Applied != tC.expectedApplied {
+				t.Errorf("expected '%v', got '%v'", tC.expectedApplied, observer.result.Applied)
+			}
+			if observer.result.Err != err {
+				t.Errorf("expected '%v', got '%v'", err, observer.result.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package valkyrie
+
+import (
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// OutOfOrderPolicy decides what happens to pending migrations dated before
+// migrations that were already applied. A fresh install would run them in a
+// different order than an existing database.
+type OutOfOrderPolicy string
+
+const (
+	OutOfOrderAllow  OutOfOrderPolicy = "allow"
+	OutOfOrderWarn   OutOfOrderPolicy = "warn"
+	OutOfOrderRefuse OutOfOrderPolicy = "refuse"
+)
+
+func ParseOutOfOrderPolicy(policy string) (OutOfOrderPolicy, error) {
+	switch p := OutOfOrderPolicy(policy); p {
+	case OutOfOrderAllow, OutOfOrderWarn, OutOfOrderRefuse:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid out-of-order policy '%s', expected one of: allow, warn, refuse", policy)
+}
+
+// MigrationPlan is the result of comparing the migration folder with the database.
+type MigrationPlan struct {
+	// Groups found in the migration folder.
+	Groups []*models.MigrationGroup
+	// Applied groups logged in the database.
+	Applied []models.MigrationGroup
+	// Pending groups, containing only the migrations that still need to run.
+	Pending []*models.MigrationGroup
+	// OutOfOrder pending migrations.
+	OutOfOrder []OutOfOrderMigration
+}
+
+// OutOfOrderMigration is a pending migration dated before an applied one.
+type OutOfOrderMigration struct {
+	GroupName     string
+	MigrationName string
+	// LatestApplied is the '<group>/<file>' reference of the newest applied
+	// migration it precedes.
+	LatestApplied string
+	// AcrossGroups is set when the newer migration belongs to a different group.
+	AcrossGroups bool
+}
+
+func (o OutOfOrderMigration) String() string {
+	scope := "in its group"
+	if o.AcrossGroups {
+		scope = "across groups"
+	}
+
+	return fmt.Sprintf("%s/%s is dated before applied migration %s (%s)", o.GroupName, o.MigrationName, o.LatestApplied, scope)
+}
+
+// FindOutOfOrder returns the out-of-order entry for a pending migration, or nil.
+func (plan *MigrationPlan) FindOutOfOrder(groupName, migrationName string) *OutOfOrderMigration {
+	for i, o := range plan.OutOfOrder {
+		if o.GroupName == groupName && o.MigrationName == migrationName {
+			return &plan.OutOfOrder[i]
+		}
+	}
+
+	return nil
+}
+
+// Print writes the pending groups and their migrations to stdout, flagging
+// out-of-order migrations.
+func (plan *MigrationPlan) Print() {
+	fmt.Println("Groups to execute:")
+	for _, group := range plan.Pending {
+		fmt.Printf("* %s\n", group.Name)
+		fmt.Printf("\t - migrations: %v\n", group.MigrationCount)
+
+		for _, mig := range group.Migrations {
+			if o := plan.FindOutOfOrder(group.Name, mig.Name); o != nil {
+				fmt.Printf("\t   %s [out of order: dated before %s]\n", mig.Name, o.LatestApplied)
+			} else {
+				fmt.Printf("\t   %s\n", mig.Name)
+			}
+		}
+
+		if len(group.Repeatables) > 0 {
+			fmt.Printf("\t - repeatable: %v\n", len(group.Repeatables))
+			for _, mig := range group.Repeatables {
+				fmt.Printf("\t   %s\n", mig.Name)
+			}
+		}
+	}
+	fmt.Printf("********\n\n")
+}
+
+func findPlanGroup(plan *MigrationPlan, groupName string) *models.MigrationGroup {
+	for _, group := range plan.Pending {
+		if group.Name == groupName {
+			return group
+		}
+	}
+
+	return nil
+}
+
+func findOutOfOrder(pending []*models.MigrationGroup, applied []models.MigrationGroup) []OutOfOrderMigration {
+	latestByGroup := make(map[string]string)
+	latestDate, latestRef := "", ""
+
+	for _, group := range applied {
+		for _, mig := range group.Migrations {
+			date := migrations.MigrationDate(mig.Name)
+
+			if date > migrations.MigrationDate(latestByGroup[group.Name]) {
+				latestByGroup[group.Name] = mig.Name
+			}
+
+			if date > latestDate {
+				latestDate, latestRef = date, group.Name+"/"+mig.Name
+			}
+		}
+	}
+
+	outOfOrder := make([]OutOfOrderMigration, 0)
+	for _, group := range pending {
+		groupLatest := latestByGroup[group.Name]
+
+		for _, mig := range group.Migrations {
+			date := migrations.MigrationDate(mig.Name)
+
+			if groupLatest != "" && date < migrations.MigrationDate(groupLatest) {
+				outOfOrder = append(outOfOrder, OutOfOrderMigration{
+					GroupName:     group.Name,
+					MigrationName: mig.Name,
+					LatestApplied: group.Name + "/" + groupLatest,
+				})
+			} else if date < latestDate {
+				outOfOrder = append(outOfOrder, OutOfOrderMigration{
+					GroupName:     group.Name,
+					MigrationName: mig.Name,
+					LatestApplied: latestRef,
+					AcrossGroups:  true,
+				})
+			}
+		}
+	}
+
+	return outOfOrder
+}
+
+func (app MigrateApp) checkOutOfOrder(plan *MigrationPlan) error {
+	if len(plan.OutOfOrder) == 0 || app.outOfOrderPolicy == OutOfOrderAllow {
+		return nil
+	}
+
+	for _, o := range plan.OutOfOrder {
+		fmt.Printf("warning: out-of-order migration %s\n", o)
+	}
+
+	if app.outOfOrderPolicy == OutOfOrderRefuse {
+		return fmt.Errorf("found %v out-of-order migration(s), refusing to migrate", len(plan.OutOfOrder))
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package valkyrie_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+type fakeRepo struct {
+	applied []models.MigrationGroup
+}
+
+func (repo *fakeRepo) EnsureCreated() error { return nil }
+
+func (repo *fakeRepo) MissingTables() ([]string, error) { return nil, nil }
+
+func (repo *fakeRepo) GetMigrations() ([]models.MigrationGroup, error) {
+	return repo.applied, nil
+}
+
+func (repo *fakeRepo) ExecuteMigrations(context.Context, []*models.MigrationGroup, models.ExecutionHooks) error {
+	return nil
+}
+
+func (repo *fakeRepo) MarkApplied(groupName, migrationName, reason string) error { return nil }
+
+func (repo *fakeRepo) MarkAllApplied(marks []models.AppliedMark) ([]models.AppliedMark, error) {
+	return marks, nil
+}
+
+func (repo *fakeRepo) MarkPending(groupName, migrationName, reason string) error { return nil }
+
+func (repo *fakeRepo) Squash(groupName string, squashed []string, baseline string, reason string) error {
+	return nil
+}
+
+func (repo *fakeRepo) GetRepeatableChecksums() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (repo *fakeRepo) Diagnose() ([]models.TrackingIssue, error) { return nil, nil }
+
+func (repo *fakeRepo) Repair() ([]models.TrackingIssue, error) { return nil, nil }
+
+func appliedGroup(id uint, name string, files ...string) models.MigrationGroup {
+	group := models.MigrationGroup{
+		Id:             id,
+		Name:           name,
+		MigrationCount: len(files),
+	}
+
+	for _, file := range files {
+		group.AddMigration(models.Migration{Name: file, GroupName: name})
+	}
+
+	return group
+}
+
+func getMigrationDirPath() string {
+	wd, _ := os.Getwd()
+	if runtime.GOOS == "windows" {
+		wd = strings.ReplaceAll(wd, "\\", "/")
+	}
+	return path.Join(wd, "../../test/MigrationDir")
+}
+
+func TestPlanOutOfOrder(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		applied            []models.MigrationGroup
+		expectedPending    int
+		expectedOutOfOrder []valkyrie.OutOfOrderMigration
+	}{
+		{
+			desc:            "fresh database",
+			applied:         nil,
+			expectedPending: 2,
+		},
+		{
+			desc: "older file in group",
+			applied: []models.MigrationGroup{
+				appliedGroup(1, "Entity", "20240309_first.sql", "20240312_later.sql"),
+			},
+			expectedPending: 2,
+			expectedOutOfOrder: []valkyrie.OutOfOrderMigration{
+				{GroupName: "AnotherEntity", MigrationName: "20240311_alter.sql", LatestApplied: "Entity/20240312_later.sql", AcrossGroups: true},
+				{GroupName: "AnotherEntity", MigrationName: "20240311_upd.sql", LatestApplied: "Entity/20240312_later.sql", AcrossGroups: true},
+				{GroupName: "Entity", MigrationName: "20240310_cr.sql", LatestApplied: "Entity/20240312_later.sql"},
+			},
+		},
+		{
+			desc: "older file across groups",
+			applied: []models.MigrationGroup{
+				appliedGroup(1, "AnotherEntity", "20240311_alter.sql", "20240311_upd.sql"),
+			},
+			expectedPending: 1,
+			expectedOutOfOrder: []valkyrie.OutOfOrderMigration{
+				{GroupName: "Entity", MigrationName: "20240310_cr.sql", LatestApplied: "AnotherEntity/20240311_alter.sql", AcrossGroups: true},
+			},
+		},
+		{
+			desc: "same date as applied file",
+			applied: []models.MigrationGroup{
+				appliedGroup(1, "AnotherEntity", "20240311_alter.sql"),
+				appliedGroup(2, "Entity", "20240310_cr.sql"),
+			},
+			expectedPending: 1,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			app := valkyrie.NewMigrateApp(&fakeRepo{applied: tC.applied})
+
+			plan, err := app.Plan(getMigrationDirPath())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if count := len(plan.Pending); count != tC.expectedPending {
+				t.Errorf("expected %v pending groups, got %v", tC.expectedPending, count)
+			}
+
+			if len(plan.OutOfOrder) != len(tC.expectedOutOfOrder) {
+				t.Fatalf("expected %v out-of-order migrations, got %v", len(tC.expectedOutOfOrder), plan.OutOfOrder)
+			}
+
+			for i, expected := range tC.expectedOutOfOrder {
+				if plan.OutOfOrder[i] != expected {
+					t.Errorf("expected '%v', got '%v'", expected, plan.OutOfOrder[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanTarget(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		migrationRef  string
+		date          string
+		steps         int
+		expectedErr   bool
+		expectedFiles []string
+	}{
+		{
+			desc:          "no target",
+			expectedFiles: []string{"20240311_alter.sql", "20240311_upd.sql", "20240310_cr.sql"},
+		},
+		{
+			desc:          "to migration",
+			migrationRef:  "AnotherEntity/20240311_upd.sql",
+			expectedFiles: []string{"20240311_alter.sql", "20240311_upd.sql"},
+		},
+		{
+			desc:         "to missing migration",
+			migrationRef: "AnotherEntity/20240312_missing.sql",
+			expectedErr:  true,
+		},
+		{
+			desc:          "to date",
+			date:          "20240310",
+			expectedFiles: []string{"20240310_cr.sql"},
+		},
+		{
+			desc:          "steps",
+			steps:         1,
+			expectedFiles: []string{"20240311_alter.sql"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			target, err := valkyrie.NewMigrationTarget(tC.migrationRef, tC.date, tC.steps)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			app := valkyrie.NewMigrateApp(&fakeRepo{}, valkyrie.WithTarget(target))
+
+			plan, err := app.Plan(getMigrationDirPath())
+			if (err != nil) != tC.expectedErr {
+				t.Fatalf("expected error: '%v', got '%v'", tC.expectedErr, err)
+			} else if err != nil {
+				return
+			}
+
+			files := make([]string, 0)
+			for _, group := range plan.Pending {
+				for _, mig := range group.Migrations {
+					files = append(files, mig.Name)
+				}
+			}
+
+			if strings.Join(files, ",") != strings.Join(tC.expectedFiles, ",") {
+				t.Errorf("expected %v, got %v", tC.expectedFiles, files)
+			}
+		})
+	}
+}
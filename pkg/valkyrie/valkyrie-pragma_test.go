@@ -0,0 +1,165 @@
+package valkyrie_test
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestApplySqlitePragmas(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		connString  string
+		pragmas     models.SqlitePragmas
+		expected    string
+		expectedErr bool
+	}{
+		{
+			desc:       "no pragmas",
+			connString: "app.db",
+			expected:   "app.db",
+		},
+		{
+			desc:       "file",
+			connString: "app.db",
+			pragmas:    models.SqlitePragmas{ForeignKeys: "on", JournalMode: "WAL", BusyTimeout: 5 * time.Second},
+			expected:   "app.db?_busy_timeout=5000&_foreign_keys=on&_journal_mode=wal",
+		},
+		{
+			desc:       "replaces aliases",
+			connString: "file:app.db?cache=shared&_fk=0",
+			pragmas:    models.SqlitePragmas{ForeignKeys: "on", Synchronous: "normal"},
+			expected:   "file:app.db?_foreign_keys=on&_synchronous=normal&cache=shared",
+		},
+		{
+			desc:        "invalid journal mode",
+			connString:  "app.db",
+			pragmas:     models.SqlitePragmas{JournalMode: "fast"},
+			expectedErr: true,
+		},
+		{
+			desc:        "postgres",
+			connString:  "postgres://localhost/app",
+			pragmas:     models.SqlitePragmas{ForeignKeys: "on"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			connString, err := valkyrie.ApplySqlitePragmas(tC.connString, "", tC.pragmas)
+
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+				return
+			}
+
+			if connString != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, connString)
+			}
+		})
+	}
+}
+
+func TestRunPragmaToggles(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		migration   string
+		expectedErr bool
+	}{
+		{
+			desc:      "rebuild with foreign keys off",
+			migration: "-- valkyrie:pragma foreign_keys=OFF\nCREATE TABLE users_new (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '');\nINSERT INTO users_new SELECT id, '' FROM users;\nDROP TABLE users;\nALTER TABLE users_new RENAME TO users;",
+		},
+		{
+			desc:        "rebuild with foreign keys on",
+			migration:   "CREATE TABLE users_new (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '');\nINSERT INTO users_new SELECT id, '' FROM users;\nDROP TABLE users;\nALTER TABLE users_new RENAME TO users;",
+			expectedErr: true,
+		},
+		{
+			desc:        "violations are checked",
+			migration:   "-- valkyrie:pragma foreign_keys=OFF\nDELETE FROM users;",
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+
+			connString, err := valkyrie.ApplySqlitePragmas(path.Join(dir, "pragma.db"), "", models.SqlitePragmas{ForeignKeys: "on"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);\nCREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id));\nINSERT INTO users VALUES (1);\nINSERT INTO posts VALUES (1, 1);")
+			writeMigration(t, migrationFolder, "Users", "20240102_rebuild_users.sql", tC.migration)
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = valkyrie.NewMigrateApp(repo).Run(migrationFolder)
+			if (err != nil) != tC.expectedErr {
+				t.Fatalf("expected error: '%v', got '%v'", tC.expectedErr, err)
+			}
+
+			// the toggles are restored once the run ends
+			db, err := helpers.GetDb(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			var foreignKeys int
+			if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+				t.Fatal(err)
+			}
+			if foreignKeys != 1 {
+				t.Errorf("expected '%v', got '%v'", 1, foreignKeys)
+			}
+		})
+	}
+}
+
+func TestInitPageSize(t *testing.T) {
+	connString := path.Join(t.TempDir(), "init.db")
+	opts := valkyrie.InitOptions{
+		Pragmas:  models.SqlitePragmas{JournalMode: "wal"},
+		PageSize: 8192,
+	}
+
+	if err := valkyrie.InitWithOptions(connString, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := helpers.GetDb(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var pageSize int
+	var journalMode string
+	if err := db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatal(err)
+	}
+
+	if pageSize != opts.PageSize || journalMode != "wal" {
+		t.Errorf("expected '%v %v', got '%v %v'", opts.PageSize, "wal", pageSize, journalMode)
+	}
+
+	opts.PageSize = 1024
+	if err := valkyrie.InitWithOptions(connString, opts); err == nil {
+		t.Errorf("expected error changing the page size of an existing database")
+	}
+}
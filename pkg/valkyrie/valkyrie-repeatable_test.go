@@ -0,0 +1,72 @@
+package valkyrie_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestRunRepeatable(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	connString := path.Join(dir, "repeatable.db")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	writeMigration(t, migrationFolder, "Users", "R_users_view.sql", "DROP VIEW IF EXISTS users_view; CREATE VIEW users_view AS SELECT id FROM users;")
+	writeMigration(t, migrationFolder, path.Join("Users", "repeatable"), "ids_view.sql", "DROP VIEW IF EXISTS ids_view; CREATE VIEW ids_view AS SELECT id FROM users;")
+
+	testCases := []struct {
+		desc     string
+		change   func()
+		expected int
+	}{
+		{
+			desc:     "first run",
+			change:   func() {},
+			expected: 2,
+		},
+		{
+			desc:     "unchanged",
+			change:   func() {},
+			expected: 2,
+		},
+		{
+			desc: "changed content",
+			change: func() {
+				writeMigration(t, migrationFolder, "Users", "R_users_view.sql", "DROP VIEW IF EXISTS users_view; CREATE VIEW users_view AS SELECT id, 1 AS active FROM users;")
+			},
+			expected: 3,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			tC.change()
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := helpers.GetDb(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			var runs int
+			if err := db.QueryRow("SELECT count(*) FROM repeatable_migration").Scan(&runs); err != nil {
+				t.Fatal(err)
+			}
+			if runs != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, runs)
+			}
+		})
+	}
+}
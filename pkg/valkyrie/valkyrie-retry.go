@@ -0,0 +1,82 @@
+package valkyrie
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/repository"
+)
+
+// RetryPolicy retries runs failing with transient errors, like a connection
+// lost on a failover, a deadlock or a lock timeout. The run's transaction is
+// rolled back, so every attempt starts over from the first migration not
+// logged as applied and runs the sql hooks and the group and file hook
+// commands again.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, values under 2 don't retry.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, it doubles after every
+	// other attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// WithRetry retries runs failing with transient errors as the policy says.
+func WithRetry(policy RetryPolicy) MigrateOption {
+	return func(app *MigrateApp) {
+		app.retryPolicy = policy
+	}
+}
+
+// backoff returns the wait before the given retry, counting from 1.
+func (policy RetryPolicy) backoff(retry int) time.Duration {
+	wait := policy.Backoff
+	for i := 1; i < retry && (policy.MaxBackoff == 0 || wait < policy.MaxBackoff); i++ {
+		wait *= 2
+	}
+
+	if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+		return policy.MaxBackoff
+	}
+
+	return wait
+}
+
+// retry calls attempt until it succeeds, fails with an error that isn't
+// transient, runs out of attempts or ctx is done.
+func (app MigrateApp) retry(ctx context.Context, attempt func() (int, error)) (int, error) {
+	maxAttempts := app.retryPolicy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for i := 1; ; i++ {
+		if i > 1 {
+			fmt.Printf("attempt %d of %d\n", i, maxAttempts)
+		}
+
+		count, err := attempt()
+		if err == nil {
+			return count, nil
+		}
+
+		if ctx.Err() != nil || !repository.IsTransient(err) {
+			return 0, err
+		} else if i == maxAttempts {
+			if maxAttempts > 1 {
+				return 0, fmt.Errorf("giving up after %d attempts: %w", maxAttempts, err)
+			}
+			return 0, err
+		}
+
+		wait := app.retryPolicy.backoff(i)
+		fmt.Printf("attempt %d of %d failed with a transient error, retrying in %v: %v\n", i, maxAttempts, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return 0, err
+		case <-time.After(wait):
+		}
+	}
+}
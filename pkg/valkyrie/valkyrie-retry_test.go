@@ -0,0 +1,227 @@
+package valkyrie_test
+
+import (
+	"context"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestRunRetry(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		policy      valkyrie.RetryPolicy
+		expectedErr bool
+	}{
+		{
+			desc:        "no retries",
+			expectedErr: true,
+		},
+		{
+			desc:   "retries until the lock is released",
+			policy: valkyrie.RetryPolicy{MaxAttempts: 10, Backoff: 20 * time.Millisecond, MaxBackoff: 100 * time.Millisecond},
+		},
+		{
+			desc:        "runs out of attempts",
+			policy:      valkyrie.RetryPolicy{MaxAttempts: 2, Backoff: 10 * time.Millisecond},
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+			connString := path.Join(dir, "retry.db")
+
+			writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+				t.Fatal(err)
+			}
+
+			writeMigration(t, migrationFolder, "Users", "20240102_cr_roles.sql", "CREATE TABLE roles (id INTEGER PRIMARY KEY);")
+
+			// another connection holds the write lock for a while
+			db, err := helpers.GetDb(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			conn, err := db.Conn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+				t.Fatal(err)
+			}
+
+			released := make(chan struct{})
+			go func() {
+				defer close(released)
+				time.Sleep(150 * time.Millisecond)
+				conn.ExecContext(context.Background(), "ROLLBACK")
+				conn.Close()
+			}()
+			defer func() { <-released }()
+
+			opts := []valkyrie.MigrateOption{
+				valkyrie.WithTimeouts(models.Timeouts{Lock: 10 * time.Millisecond}),
+				valkyrie.WithRetry(tC.policy),
+			}
+			err = valkyrie.NewMigrateApp(repo, opts...).Run(migrationFolder)
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRunRetryLockedHook(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		policy      valkyrie.RetryPolicy
+		expectedErr bool
+	}{
+		{
+			desc:        "no retries",
+			expectedErr: true,
+		},
+		{
+			desc:   "retries until the lock is released",
+			policy: valkyrie.RetryPolicy{MaxAttempts: 10, Backoff: 20 * time.Millisecond, MaxBackoff: 100 * time.Millisecond},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+			dbPath := path.Join(dir, "retry.db")
+
+			writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+
+			// the hook waits for locks as long as the connection string says
+			repo, err := valkyrie.NewMigrationStorer(dbPath + "?_busy_timeout=10")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+				t.Fatal(err)
+			}
+
+			writeMigration(t, migrationFolder, "Users", "20240102_cr_roles.sql", "CREATE TABLE roles (id INTEGER PRIMARY KEY);")
+			writeMigration(t, migrationFolder, "Users", "_before.sql", "CREATE TABLE hook_log (id INTEGER PRIMARY KEY);")
+
+			// another connection holds the write lock for a while
+			db, err := helpers.GetDb(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			conn, err := db.Conn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+				t.Fatal(err)
+			}
+
+			released := make(chan struct{})
+			go func() {
+				defer close(released)
+				time.Sleep(150 * time.Millisecond)
+				conn.ExecContext(context.Background(), "ROLLBACK")
+				conn.Close()
+			}()
+			defer func() { <-released }()
+
+			err = valkyrie.NewMigrateApp(repo, valkyrie.WithRetry(tC.policy)).Run(migrationFolder)
+			if (err != nil) != tC.expectedErr {
+				t.Errorf("expected error: '%v', got '%v'", tC.expectedErr, err)
+			}
+		})
+	}
+}
+
+// commitLostRepo commits the first run but fails it as if the connection was
+// lost before the commit was acknowledged.
+type commitLostRepo struct {
+	models.MigrationStorer
+	lost bool
+}
+
+func (repo *commitLostRepo) ExecuteMigrations(ctx context.Context, groups []*models.MigrationGroup, hooks models.ExecutionHooks) error {
+	if err := repo.MigrationStorer.ExecuteMigrations(ctx, groups, hooks); err != nil {
+		return err
+	}
+
+	if !repo.lost {
+		repo.lost = true
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func TestRunRetryAfterLostCommit(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	connString := path.Join(dir, "retry.db")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")
+	writeMigration(t, migrationFolder, "Users", "20240102_ins_users.sql", "INSERT INTO users (name) VALUES ('admin');")
+	writeMigration(t, migrationFolder, "Users", "R_users_view.sql", "DROP VIEW IF EXISTS users_view; CREATE VIEW users_view AS SELECT id FROM users;")
+
+	storer, err := valkyrie.NewMigrationStorer(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &commitLostRepo{MigrationStorer: storer}
+	policy := valkyrie.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	if err := valkyrie.NewMigrateApp(repo, valkyrie.WithRetry(policy)).Run(migrationFolder); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := helpers.GetDb(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testCases := []struct {
+		desc     string
+		query    string
+		expected int
+	}{
+		{desc: "data migrations", query: "SELECT count(*) FROM users", expected: 1},
+		{desc: "migration rows", query: "SELECT count(*) FROM migration", expected: 2},
+		{desc: "group rows", query: "SELECT count(*) FROM migration_group", expected: 1},
+		{desc: "repeatable migration rows", query: "SELECT count(*) FROM repeatable_migration", expected: 1},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var count int
+			if err := db.QueryRow(tC.query).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+
+			if count != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package valkyrie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+var ErrRoundTripFailed = errors.New("some migrations aren't reversible or idempotent")
+
+const (
+	RoundTripIssueApply         = "fails to apply"
+	RoundTripIssueRevert        = "fails to revert"
+	RoundTripIssueReversibility = "not reversible"
+	RoundTripIssueIdempotency   = "not idempotent"
+)
+
+// RoundTripIssue is a migration that broke the round trip.
+type RoundTripIssue struct {
+	GroupName     string
+	MigrationName string
+	Kind          string
+	Detail        string
+}
+
+func (issue RoundTripIssue) String() string {
+	return fmt.Sprintf("%s/%s: %s (%s)", issue.GroupName, issue.MigrationName, issue.Kind, issue.Detail)
+}
+
+// roundTrip applies and reverts the migrations of a folder one at a time on
+// a shadow database.
+type roundTrip struct {
+	migrationFolder string
+	shadow          *ShadowDb
+	repo            models.MigrationStorer
+	// groups keeps the logged group of every group name, so later files
+	// are logged to the same group row.
+	groups map[string]*models.MigrationGroup
+	issues []RoundTripIssue
+}
+
+type roundTripStep struct {
+	group string
+	mig   models.Migration
+}
+
+// RoundTrip applies every migration of the folder to a scratch database (an
+// in-memory SQLite database, or a temporary schema of the Postgres database
+// referenced by connString), reverts them in reverse order with their down
+// scripts and applies them again, checking the schema after each step.
+func RoundTrip(migrationFolder string, connString string, opts ...MigrateOption) error {
+	driver := DriverSqlite
+	if connString != "" {
+		var err error
+		if driver, err = ResolveDriver(connString); err != nil {
+			return err
+		}
+	}
+
+	shadow, err := OpenShadowDb(connString, driver)
+	if err != nil {
+		return err
+	}
+	defer shadow.Close()
+
+	rt := &roundTrip{
+		migrationFolder: migrationFolder,
+		shadow:          shadow,
+		repo:            NewMigrationStorerForDb(shadow.Db, driver),
+		groups:          make(map[string]*models.MigrationGroup),
+	}
+
+	plan, err := NewMigrateApp(rt.repo, opts...).Plan(migrationFolder)
+	if err != nil {
+		return err
+	}
+
+	steps := make([]roundTripStep, 0)
+	for _, group := range plan.Pending {
+		for _, mig := range group.Migrations {
+			steps = append(steps, roundTripStep{group: group.Name, mig: mig})
+		}
+	}
+
+	if len(steps) == 0 {
+		fmt.Println("no migrations found")
+		return nil
+	}
+
+	// snapshots[i] is the schema before step i is applied
+	snapshots := make([]*schema.Schema, 0, len(steps)+1)
+	initial, err := shadow.Schema()
+	if err != nil {
+		return err
+	}
+	snapshots = append(snapshots, initial)
+
+	fmt.Println("applying migrations:")
+	for _, step := range steps {
+		if err := rt.apply(step); err != nil {
+			rt.report(step, RoundTripIssueApply, err.Error())
+			return rt.result(len(snapshots)-1, 0)
+		}
+
+		s, err := shadow.Schema()
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	fmt.Println("reverting migrations:")
+	reverted := len(steps)
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if step.mig.DownName == "" {
+			fmt.Printf("\t * %s/%s has no down script, stopping\n", step.group, step.mig.Name)
+			break
+		}
+
+		if err := rt.revert(step); err != nil {
+			rt.report(step, RoundTripIssueRevert, err.Error())
+			return rt.result(len(steps), len(steps)-reverted)
+		}
+
+		if !rt.check(step, RoundTripIssueReversibility, snapshots[i]) {
+			return rt.result(len(steps), len(steps)-reverted)
+		}
+		reverted = i
+	}
+
+	if reverted < len(steps) {
+		fmt.Println("re-applying migrations:")
+	}
+	for i := reverted; i < len(steps); i++ {
+		step := steps[i]
+		if err := rt.apply(step); err != nil {
+			rt.report(step, RoundTripIssueIdempotency, err.Error())
+			break
+		}
+
+		if !rt.check(step, RoundTripIssueIdempotency, snapshots[i+1]) {
+			break
+		}
+	}
+
+	return rt.result(len(steps), len(steps)-reverted)
+}
+
+func (rt *roundTrip) apply(step roundTripStep) error {
+	f, err := os.Open(path.Join(rt.migrationFolder, step.group, step.mig.Name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	group, ok := rt.groups[step.group]
+	if !ok {
+		group = &models.MigrationGroup{Name: step.group}
+		rt.groups[step.group] = group
+	}
+
+	mig := step.mig
+	mig.FReader = f
+	group.Migrations = []models.Migration{mig}
+
+	return rt.repo.ExecuteMigrations(context.Background(), []*models.MigrationGroup{group}, nil)
+}
+
+func (rt *roundTrip) revert(step roundTripStep) error {
+	buf, err := os.ReadFile(path.Join(rt.migrationFolder, step.group, step.mig.DownName))
+	if err != nil {
+		return err
+	}
+
+	tx, err := rt.shadow.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(string(buf)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\t * reverted %s/%s\n", step.group, step.mig.Name)
+	return rt.repo.MarkPending(step.group, step.mig.Name, "reverted by valkyrie test")
+}
+
+// check compares the shadow schema with the expected one, reporting an issue
+// of the given kind when they differ.
+func (rt *roundTrip) check(step roundTripStep, kind string, expected *schema.Schema) bool {
+	actual, err := rt.shadow.Schema()
+	if err != nil {
+		rt.report(step, kind, err.Error())
+		return false
+	}
+
+	diffs := schema.Compare(expected, actual)
+	if len(diffs) == 0 {
+		return true
+	}
+
+	details := make([]string, len(diffs))
+	for i, diff := range diffs {
+		details[i] = diff.String()
+	}
+	rt.report(step, kind, strings.Join(details, "; "))
+
+	return false
+}
+
+func (rt *roundTrip) report(step roundTripStep, kind string, detail string) {
+	rt.issues = append(rt.issues, RoundTripIssue{
+		GroupName:     step.group,
+		MigrationName: step.mig.Name,
+		Kind:          kind,
+		Detail:        detail,
+	})
+}
+
+func (rt *roundTrip) result(applied int, reverted int) error {
+	fmt.Printf("%v migrations applied, %v reverted and re-applied\n", applied, reverted)
+
+	if len(rt.issues) == 0 {
+		return nil
+	}
+
+	fmt.Printf("found %v issue(s):\n", len(rt.issues))
+	for _, issue := range rt.issues {
+		fmt.Printf("* %s\n", issue)
+	}
+
+	return ErrRoundTripFailed
+}
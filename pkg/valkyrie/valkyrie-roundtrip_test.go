@@ -0,0 +1,63 @@
+package valkyrie_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		files       map[string]string
+		expectedErr error
+	}{
+		{
+			desc: "reversible",
+			files: map[string]string{
+				"20240101_cr_users.sql":      "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"20240101_cr_users.down.sql": "DROP TABLE users;",
+				"20240102_add_name.sql":      "ALTER TABLE users ADD COLUMN name TEXT;",
+				"20240102_add_name.down.sql": "ALTER TABLE users DROP COLUMN name;",
+			},
+		},
+		{
+			desc: "without down scripts",
+			files: map[string]string{
+				"20240101_cr_users.sql": "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+			},
+		},
+		{
+			desc: "incomplete down script",
+			files: map[string]string{
+				"20240101_cr_users.sql":      "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"20240101_cr_users.down.sql": "DROP TABLE users;",
+				"20240102_add_name.sql":      "ALTER TABLE users ADD COLUMN name TEXT; CREATE INDEX ix_users_name ON users (name);",
+				"20240102_add_name.down.sql": "DROP INDEX ix_users_name;",
+			},
+			expectedErr: valkyrie.ErrRoundTripFailed,
+		},
+		{
+			desc: "failing migration",
+			files: map[string]string{
+				"20240101_cr_users.sql": "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"20240102_add_name.sql": "ALTER TABLE missing ADD COLUMN name TEXT;",
+			},
+			expectedErr: valkyrie.ErrRoundTripFailed,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			migrationFolder := path.Join(t.TempDir(), "migrations")
+			for name, sql := range tC.files {
+				writeMigration(t, migrationFolder, "Users", name, sql)
+			}
+
+			if err := valkyrie.RoundTrip(migrationFolder, ""); !errors.Is(err, tC.expectedErr) {
+				t.Errorf("expected '%v', got '%v'", tC.expectedErr, err)
+			}
+		})
+	}
+}
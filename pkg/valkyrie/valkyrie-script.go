@@ -0,0 +1,303 @@
+package valkyrie
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/internal/repository"
+	postgresRepo "github.com/marianop9/valkyrie-migrate/internal/repository/postgres"
+)
+
+// baselineStorer stands in for an empty database, so a plan can be computed
+// without a connection.
+type baselineStorer struct{}
+
+// NewBaselineStorer returns a MigrationStorer with no applied migrations. It
+// only supports planning, executing or editing migrations fails.
+func NewBaselineStorer() models.MigrationStorer {
+	return baselineStorer{}
+}
+
+var errBaselineStorer = fmt.Errorf("the empty baseline can't be modified")
+
+func (baselineStorer) EnsureCreated() error { return nil }
+
+func (baselineStorer) MissingTables() ([]string, error) { return []string{}, nil }
+
+func (baselineStorer) GetMigrations() ([]models.MigrationGroup, error) {
+	return []models.MigrationGroup{}, nil
+}
+
+func (baselineStorer) ExecuteMigrations(context.Context, []*models.MigrationGroup, models.ExecutionHooks) error {
+	return errBaselineStorer
+}
+
+func (baselineStorer) MarkApplied(groupName, migrationName, reason string) error {
+	return errBaselineStorer
+}
+
+func (baselineStorer) MarkAllApplied([]models.AppliedMark) ([]models.AppliedMark, error) {
+	return nil, errBaselineStorer
+}
+
+func (baselineStorer) MarkPending(groupName, migrationName, reason string) error {
+	return errBaselineStorer
+}
+
+func (baselineStorer) Squash(groupName string, squashed []string, baseline string, reason string) error {
+	return errBaselineStorer
+}
+
+func (baselineStorer) GetRepeatableChecksums() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (baselineStorer) Diagnose() ([]models.TrackingIssue, error) {
+	return []models.TrackingIssue{}, nil
+}
+
+func (baselineStorer) Repair() ([]models.TrackingIssue, error) { return nil, errBaselineStorer }
+
+// Script writes the pending plan as a single sql script for the given driver.
+// The script runs in a transaction, creates the tracking tables if needed and
+// logs every migration the same way ExecuteMigrations does, so it can be run
+// by hand instead of migrate. It includes the sql hooks, the timeouts and the
+// pragmas of the files, shell hook commands are left out. The plan is read
+// without writing to the database.
+func (app MigrateApp) Script(migrationFolder string, driver string, out io.Writer) error {
+	fsys, err := migrationFS(migrationFolder)
+	if err != nil {
+		return err
+	}
+
+	return app.ScriptFS(fsys, driver, out)
+}
+
+// ScriptFS is Script for the migration groups at the root of fsys.
+func (app MigrateApp) ScriptFS(fsys fs.FS, driver string, out io.Writer) error {
+	plan, err := app.planFS(fsys, true)
+	if err != nil {
+		return err
+	}
+
+	if err := app.checkOutOfOrder(plan); err != nil {
+		return err
+	}
+
+	var trackingDDL []string
+	switch driver {
+	case DriverPostgres:
+		trackingDDL = postgresRepo.TrackingTablesDDL()
+	case DriverSqlite:
+		trackingDDL = repository.TrackingTablesDDL()
+	default:
+		return fmt.Errorf("unsupported driver '%s', expected one of: %s, %s", driver, DriverPostgres, DriverSqlite)
+	}
+
+	// the files are read the way a run reads them, with their headers
+	pending := make([]*models.MigrationGroup, len(plan.Pending))
+	for i, group := range plan.Pending {
+		pendingGroup := *group
+		pendingGroup.Migrations = append([]models.Migration(nil), group.Migrations...)
+		pendingGroup.Repeatables = append([]models.Migration(nil), group.Repeatables...)
+		pending[i] = &pendingGroup
+
+		if err := app.readMigrations(fsys, pendingGroup.Name, pendingGroup.Migrations); err != nil {
+			return err
+		}
+		if err := app.readMigrations(fsys, pendingGroup.Name, pendingGroup.Repeatables); err != nil {
+			return err
+		}
+	}
+
+	pragmas, err := repository.RunPragmas(pending)
+	if err != nil {
+		return err
+	} else if driver == DriverPostgres && len(pragmas) > 0 {
+		return fmt.Errorf("pragma directives are only supported by SQLite databases")
+	}
+
+	sw := &scriptWriter{
+		w:      bufio.NewWriter(out),
+		driver: driver,
+		hooks:  newHookRunner(context.Background(), fsys, app.hookCommands),
+	}
+
+	fmt.Fprintf(sw.w, "-- generated by valkyrie on %s for %s\n", time.Now().Format(time.RFC3339), driver)
+	fmt.Fprintf(sw.w, "-- pending groups: %v\n", len(pending))
+	if app.hookCommands.HasCommands() {
+		fmt.Fprintln(sw.w, "-- the shell hook commands of the config file aren't part of the script")
+	}
+	fmt.Fprintln(sw.w)
+
+	// pragmas are no-ops inside a transaction, they stay set until the
+	// connection running the script closes
+	pragmaNames := make([]string, 0, len(pragmas))
+	for name := range pragmas {
+		pragmaNames = append(pragmaNames, name)
+	}
+	sort.Strings(pragmaNames)
+	for _, name := range pragmaNames {
+		fmt.Fprintf(sw.w, "PRAGMA %s = %s;\n", name, pragmas[name])
+	}
+	if len(pragmaNames) > 0 {
+		fmt.Fprintln(sw.w)
+	}
+
+	fmt.Fprintln(sw.w, "BEGIN;")
+	fmt.Fprintln(sw.w)
+
+	for _, ddl := range trackingDDL {
+		fmt.Fprintln(sw.w, ddl)
+	}
+
+	if err := sw.hook(migrations.BeforeHook); err != nil {
+		return err
+	}
+
+	for _, group := range pending {
+		if len(group.Migrations) == 0 {
+			continue
+		}
+
+		groupName := quoteLiteral(group.Name)
+
+		fmt.Fprintf(sw.w, "\n-- group %s\n", group.Name)
+		fmt.Fprintf(sw.w, "INSERT INTO migration_group (name)\nSELECT %s\nWHERE NOT EXISTS (SELECT 1 FROM migration_group WHERE name = %s);\n", groupName, groupName)
+
+		if err := sw.hook(path.Join(group.Name, migrations.BeforeHook)); err != nil {
+			return err
+		}
+
+		for i := range group.Migrations {
+			mig := &group.Migrations[i]
+
+			if err := sw.file(fmt.Sprintf("%s/%s", group.Name, mig.Name), mig); err != nil {
+				return err
+			}
+			fmt.Fprintf(sw.w, "INSERT INTO migration (migration_group_id, name, executed_at)\nVALUES ((SELECT min(id) FROM migration_group WHERE name = %s), %s, CURRENT_TIMESTAMP);\n",
+				groupName, quoteLiteral(mig.Name))
+		}
+
+		if err := sw.hook(path.Join(group.Name, migrations.AfterHook)); err != nil {
+			return err
+		}
+	}
+
+	// repeatable migrations run after every versioned one
+	for _, group := range pending {
+		for i := range group.Repeatables {
+			mig := &group.Repeatables[i]
+
+			if err := sw.file(fmt.Sprintf("repeatable %s/%s", group.Name, mig.Name), mig); err != nil {
+				return err
+			}
+			fmt.Fprintf(sw.w, "INSERT INTO repeatable_migration (group_name, name, checksum, executed_at)\nVALUES (%s, %s, %s, CURRENT_TIMESTAMP);\n",
+				quoteLiteral(group.Name), quoteLiteral(mig.Name), quoteLiteral(mig.Checksum))
+		}
+	}
+
+	if err := sw.hook(migrations.AfterHook); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(sw.w)
+	fmt.Fprintln(sw.w, "COMMIT;")
+
+	return sw.w.Flush()
+}
+
+// scriptWriter writes the statements a run executes, in the order it does.
+type scriptWriter struct {
+	w      *bufio.Writer
+	driver string
+	hooks  *hookRunner
+	// timeouts are the ones set on the transaction so far
+	timeouts models.Timeouts
+}
+
+// hook writes the hook file at hookPath, if the migration folder has it.
+func (sw *scriptWriter) hook(hookPath string) error {
+	hookSql, err := sw.hooks.readSql(hookPath)
+	if err != nil {
+		return err
+	} else if hookSql == "" {
+		return nil
+	}
+
+	fmt.Fprintf(sw.w, "\n-- hook %s\n", hookPath)
+	fmt.Fprintln(sw.w, scriptStatements([]byte(hookSql)))
+	return nil
+}
+
+// file writes a migration file between its sql hooks, with its timeouts.
+func (sw *scriptWriter) file(label string, mig *models.Migration) error {
+	if err := sw.hook(migrations.BeforeEachHook); err != nil {
+		return err
+	}
+	if err := sw.hook(path.Join(mig.GroupName, migrations.BeforeEachHook)); err != nil {
+		return err
+	}
+
+	buf, err := io.ReadAll(mig.FReader)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(sw.w, "\n-- %s\n", label)
+	sw.setTimeouts(mig.Timeouts)
+	fmt.Fprintln(sw.w, scriptStatements(buf))
+
+	if err := sw.hook(path.Join(mig.GroupName, migrations.AfterEachHook)); err != nil {
+		return err
+	}
+
+	return sw.hook(migrations.AfterEachHook)
+}
+
+// setTimeouts writes the statements applying the timeouts of a file, as the
+// repository of the driver does.
+func (sw *scriptWriter) setTimeouts(timeouts models.Timeouts) {
+	if sw.driver == DriverPostgres {
+		if timeouts != sw.timeouts {
+			fmt.Fprintln(sw.w, postgresRepo.TimeoutsSql(timeouts))
+			sw.timeouts = timeouts
+		}
+		return
+	}
+
+	// SQLite's lock timeout is the busy timeout of the connection, the
+	// statement timeout is a deadline only a run can enforce
+	if timeouts.Lock > 0 && timeouts.Lock != sw.timeouts.Lock {
+		fmt.Fprintf(sw.w, "PRAGMA busy_timeout = %d;\n", timeouts.Lock.Milliseconds())
+		sw.timeouts.Lock = timeouts.Lock
+	}
+	if timeouts.Statement > 0 {
+		fmt.Fprintf(sw.w, "-- statement timeout of %v, not enforced by the script\n", timeouts.Statement)
+	}
+}
+
+// scriptStatements terminates the statements of a file so the next one can
+// follow. The terminator goes on its own line, as the file may end with a
+// line comment.
+func scriptStatements(buf []byte) string {
+	statements := strings.TrimSpace(string(buf))
+	if !strings.HasSuffix(statements, ";") {
+		statements += "\n;"
+	}
+
+	return statements
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,158 @@
+package valkyrie_test
+
+import (
+	"bytes"
+	"database/sql"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+// queryRows returns the rows of query with their columns joined by '|'.
+func queryRows(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make([]string, 0)
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = value.String
+		}
+		result = append(result, strings.Join(row, "|"))
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestScriptMatchesRun(t *testing.T) {
+	fsys := fstest.MapFS{
+		"_before.sql":                  {Data: []byte("CREATE TABLE hook_log (id INTEGER PRIMARY KEY, hook TEXT);")},
+		"_after.sql":                   {Data: []byte("INSERT INTO hook_log (hook) VALUES ('after run');")},
+		"_before_each.sql":             {Data: []byte("INSERT INTO hook_log (hook) VALUES ('before file');")},
+		"Users/_before.sql":            {Data: []byte("INSERT INTO hook_log (hook) VALUES ('before group');")},
+		"Users/_after_each.sql":        {Data: []byte("INSERT INTO hook_log (hook) VALUES ('after file');")},
+		"Users/20240101_cr_users.sql":  {Data: []byte("-- valkyrie:lock-timeout 2s\nCREATE TABLE users (id INTEGER PRIMARY KEY);")},
+		"Users/20240102_cr_orgs.sql":   {Data: []byte("-- valkyrie:pragma foreign_keys=OFF\nCREATE TABLE orgs (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id));\nINSERT INTO users (id) VALUES (1);\nINSERT INTO orgs (id, user_id) VALUES (1, 1);")},
+		"Users/R_users_view.sql":       {Data: []byte("DROP VIEW IF EXISTS users_view; CREATE VIEW users_view AS SELECT id FROM users;")},
+		"Orders/20240103_cr_order.sql": {Data: []byte("-- valkyrie:statement-timeout 1m\nCREATE TABLE orders (id INTEGER PRIMARY KEY);")},
+		"Orders/20240104_cr_items.sql": {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY)\n-- the last statement isn't terminated")},
+	}
+
+	dir := t.TempDir()
+	runConn := path.Join(dir, "run.db")
+	scriptConn := path.Join(dir, "script.db")
+
+	runRepo, err := valkyrie.NewMigrationStorer(runConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := valkyrie.NewMigrateApp(runRepo).RunFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	scriptRepo, err := valkyrie.NewMigrationStorer(scriptConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var script bytes.Buffer
+	if err := valkyrie.NewMigrateApp(scriptRepo).ScriptFS(fsys, valkyrie.DriverSqlite, &script); err != nil {
+		t.Fatal(err)
+	}
+
+	runDb, err := helpers.GetDb(runConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runDb.Close()
+
+	scriptDb, err := helpers.GetDb(scriptConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scriptDb.Close()
+
+	if tables := queryRows(t, scriptDb, "SELECT name FROM sqlite_master"); len(tables) != 0 {
+		t.Fatalf("expected the script to leave the database untouched, got tables %v", tables)
+	}
+
+	if _, err := scriptDb.Exec(script.String()); err != nil {
+		t.Fatalf("failed to run the script: %v\n%s", err, script.String())
+	}
+
+	testCases := []struct {
+		desc  string
+		query string
+	}{
+		{
+			desc:  "schema",
+			query: "SELECT type, name, sql FROM sqlite_master ORDER BY type, name",
+		},
+		{
+			desc:  "hooks",
+			query: "SELECT id, hook FROM hook_log ORDER BY id",
+		},
+		{
+			desc:  "migrations",
+			query: "SELECT mg.name, m.name FROM migration m JOIN migration_group mg ON mg.id = m.migration_group_id ORDER BY m.id",
+		},
+		{
+			desc:  "repeatable migrations",
+			query: "SELECT group_name, name, checksum FROM repeatable_migration ORDER BY id",
+		},
+		{
+			desc:  "data",
+			query: "SELECT o.id, u.id FROM orgs o JOIN users u ON u.id = o.user_id",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			expected := queryRows(t, runDb, tC.query)
+			got := queryRows(t, scriptDb, tC.query)
+
+			if len(expected) == 0 {
+				t.Fatalf("expected the run to write rows for '%s'", tC.query)
+			}
+
+			if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected '%v', got '%v'", expected, got)
+			}
+		})
+	}
+
+	for _, statement := range []string{"PRAGMA foreign_keys = OFF;", "PRAGMA busy_timeout = 2000;"} {
+		if !strings.Contains(script.String(), statement) {
+			t.Errorf("expected the script to contain '%s'", statement)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package valkyrie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+// SeedMode decides which seeds of the selected profiles run.
+type SeedMode string
+
+const (
+	// SeedPending only runs seeds that never ran.
+	SeedPending SeedMode = "pending"
+	// SeedChanged also re-runs seeds whose content changed, meant for seeds
+	// written as upserts.
+	SeedChanged SeedMode = "changed"
+	// SeedAll re-runs every seed.
+	SeedAll SeedMode = "all"
+)
+
+func ParseSeedMode(mode string) (SeedMode, error) {
+	switch m := SeedMode(mode); m {
+	case SeedPending, SeedChanged, SeedAll:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("invalid seed mode '%s', expected one of: pending, changed, all", mode)
+}
+
+type SeedOptions struct {
+	// Profiles are the folders of the seed folder to run, in order.
+	Profiles []string
+	Mode     SeedMode
+	// Reset runs the profiles' reset scripts and every seed again.
+	Reset bool
+	// MigrationFolder is migrated before seeding, so seeds always run on the
+	// latest schema. Seed requires it.
+	MigrationFolder string
+}
+
+var ErrSeedWithoutMigrations = errors.New("seeds run after the schema migrations, a migration folder is required")
+
+// Seed runs the seeds of the selected profiles of seedFolder on the database
+// referenced by connString, after applying the pending schema migrations of
+// opts.MigrationFolder.
+func Seed(connString string, seedFolder string, opts SeedOptions, migrateOpts ...MigrateOption) error {
+	if opts.MigrationFolder == "" {
+		return ErrSeedWithoutMigrations
+	}
+
+	fsys, err := migrationFS(seedFolder)
+	if err != nil {
+		return err
+	}
+
+	db, driver, err := OpenDb(connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	app := NewMigrateApp(NewMigrationStorerForDb(db, driver), migrateOpts...)
+	if err := app.Run(opts.MigrationFolder); err != nil {
+		return err
+	}
+
+	return SeedFS(NewSeedStorerForDb(db, driver), fsys, opts)
+}
+
+// SeedFS runs the seeds of the selected profile folders at the root of fsys.
+func SeedFS(store models.SeedStorer, fsys fs.FS, opts SeedOptions) error {
+	mode := opts.Mode
+	if mode == "" {
+		mode = SeedPending
+	}
+
+	if err := store.EnsureSeedsCreated(); err != nil {
+		fmt.Println("failed to create seed table")
+		return err
+	}
+
+	checksums, err := store.GetSeedChecksums()
+	if err != nil {
+		return err
+	}
+
+	resets := make([]models.Seed, 0)
+	seeds := make([]models.Seed, 0)
+
+	for _, profile := range opts.Profiles {
+		profileSeeds, hasReset, err := migrations.GetSeedsFS(fsys, profile)
+		if err != nil {
+			return err
+		}
+
+		if opts.Reset {
+			reset := models.Seed{Profile: profile, Name: migrations.ResetSeed}
+			if hasReset {
+				buf, err := fs.ReadFile(fsys, path.Join(profile, migrations.ResetSeed))
+				if err != nil {
+					return err
+				}
+				reset.FReader = bytes.NewReader(buf)
+			}
+			// later profiles may reference the data of earlier ones
+			resets = append([]models.Seed{reset}, resets...)
+		}
+
+		for _, seed := range profileSeeds {
+			buf, err := fs.ReadFile(fsys, path.Join(profile, seed.Name))
+			if err != nil {
+				return fmt.Errorf("failed to read file %v: %v", seed.Name, err)
+			}
+
+			sum := sha256.Sum256(buf)
+			seed.Checksum = hex.EncodeToString(sum[:])
+			seed.FReader = bytes.NewReader(buf)
+
+			lastChecksum, ran := checksums[models.SeedKey(profile, seed.Name)]
+			switch {
+			case opts.Reset, mode == SeedAll, !ran:
+			case mode == SeedChanged && lastChecksum != seed.Checksum:
+			default:
+				continue
+			}
+
+			seeds = append(seeds, seed)
+		}
+	}
+
+	if len(resets) == 0 && len(seeds) == 0 {
+		fmt.Println("seeds are up to date. Exiting...")
+		return nil
+	}
+
+	fmt.Printf("seeding %v file(s):\n", len(seeds))
+
+	return store.ExecuteSeeds(resets, seeds)
+}
@@ -0,0 +1,129 @@
+package valkyrie_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestSeedFS(t *testing.T) {
+	db, err := helpers.GetDb(path.Join(t.TempDir(), "seed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// an application table named like the seed concept mustn't clash with the tracking table
+	if _, err := db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL); CREATE TABLE seed (id INTEGER PRIMARY KEY, label TEXT);"); err != nil {
+		t.Fatal(err)
+	}
+
+	store := valkyrie.NewSeedStorerForDb(db, valkyrie.DriverSqlite)
+	upsert := "INSERT INTO users (id, name) VALUES (1, '%s') ON CONFLICT (id) DO UPDATE SET name = excluded.name;"
+
+	fsys := fstest.MapFS{
+		"dev/01_users.sql": {Data: []byte(fmt.Sprintf(upsert, "admin"))},
+		"dev/_reset.sql":   {Data: []byte("DELETE FROM users;")},
+	}
+
+	testCases := []struct {
+		desc         string
+		change       func()
+		opts         valkyrie.SeedOptions
+		expectedName string
+	}{
+		{
+			desc:         "first run",
+			opts:         valkyrie.SeedOptions{Profiles: []string{"dev"}},
+			expectedName: "admin",
+		},
+		{
+			desc: "pending skips changed seeds",
+			change: func() {
+				fsys["dev/01_users.sql"] = &fstest.MapFile{Data: []byte(fmt.Sprintf(upsert, "root"))}
+			},
+			opts:         valkyrie.SeedOptions{Profiles: []string{"dev"}, Mode: valkyrie.SeedPending},
+			expectedName: "admin",
+		},
+		{
+			desc:         "changed re-runs changed seeds",
+			opts:         valkyrie.SeedOptions{Profiles: []string{"dev"}, Mode: valkyrie.SeedChanged},
+			expectedName: "root",
+		},
+		{
+			desc: "reset re-runs every seed",
+			change: func() {
+				fsys["dev/01_users.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO users (id, name) VALUES (1, 'reset');")}
+			},
+			opts:         valkyrie.SeedOptions{Profiles: []string{"dev"}, Reset: true},
+			expectedName: "reset",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if tC.change != nil {
+				tC.change()
+			}
+
+			if err := valkyrie.SeedFS(store, fsys, tC.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			var name string
+			if err := db.QueryRow("SELECT name FROM users WHERE id = 1").Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			if name != tC.expectedName {
+				t.Errorf("expected '%v', got '%v'", tC.expectedName, name)
+			}
+		})
+	}
+
+	var runs int
+	if err := db.QueryRow("SELECT count(*) FROM seed_run").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("expected '%v', got '%v'", 1, runs)
+	}
+}
+
+func TestSeedMigratesFirst(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	seedFolder := path.Join(dir, "seeds")
+	connString := path.Join(dir, "seed.db")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);")
+	writeMigration(t, seedFolder, "dev", "01_users.sql", "INSERT INTO users (name) VALUES ('admin');")
+
+	opts := valkyrie.SeedOptions{Profiles: []string{"dev"}}
+	if err := valkyrie.Seed(connString, seedFolder, opts); !errors.Is(err, valkyrie.ErrSeedWithoutMigrations) {
+		t.Fatalf("expected '%v', got '%v'", valkyrie.ErrSeedWithoutMigrations, err)
+	}
+
+	opts.MigrationFolder = migrationFolder
+	if err := valkyrie.Seed(connString, seedFolder, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := helpers.GetDb(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var users int
+	if err := db.QueryRow("SELECT count(*) FROM users").Scan(&users); err != nil {
+		t.Fatal(err)
+	}
+	if users != 1 {
+		t.Errorf("expected '%v', got '%v'", 1, users)
+	}
+}
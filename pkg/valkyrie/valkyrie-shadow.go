@@ -0,0 +1,88 @@
+package valkyrie
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+// ShadowDb is a throwaway database used to replay migrations without touching
+// the target: an in-memory database for SQLite or a temporary schema for Postgres.
+type ShadowDb struct {
+	Db     *sql.DB
+	Driver string
+	// schemaName is the temporary Postgres schema, dropped on Close.
+	schemaName string
+}
+
+// OpenShadowDb creates an empty shadow database for the given driver. Postgres
+// shadows are created as a temporary schema of the database referenced by connString.
+func OpenShadowDb(connString string, driver string) (*ShadowDb, error) {
+	switch driver {
+	case DriverSqlite:
+		db, err := helpers.GetDb(":memory:")
+		if err != nil {
+			return nil, err
+		}
+		// every connection to :memory: opens a different database
+		db.SetMaxOpenConns(1)
+
+		return &ShadowDb{Db: db, Driver: driver}, nil
+
+	case DriverPostgres:
+		db, err := helpers.GetPostgresDb(connString)
+		if err != nil {
+			return nil, err
+		}
+		// the search_path is set per connection, so keep a single one open
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+
+		shadow := &ShadowDb{
+			Db:         db,
+			Driver:     driver,
+			schemaName: fmt.Sprintf("valkyrie_shadow_%d", time.Now().UnixNano()),
+		}
+
+		if _, err := db.Exec("CREATE SCHEMA " + shadow.schemaName); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create shadow schema: %v", err)
+		}
+
+		if _, err := db.Exec("SET search_path TO " + shadow.schemaName); err != nil {
+			shadow.Close()
+			return nil, fmt.Errorf("failed to use shadow schema: %v", err)
+		}
+
+		return shadow, nil
+	}
+
+	return nil, fmt.Errorf("unsupported driver '%s', expected one of: %s, %s", driver, DriverPostgres, DriverSqlite)
+}
+
+// Migrate applies every migration of the folder to the shadow database.
+func (s *ShadowDb) Migrate(migrationFolder string, opts ...MigrateOption) error {
+	app := NewMigrateApp(NewMigrationStorerForDb(s.Db, s.Driver), opts...)
+
+	return app.Run(migrationFolder)
+}
+
+// Schema returns the normalized schema of the shadow database.
+func (s *ShadowDb) Schema() (*schema.Schema, error) {
+	return IntrospectSchema(s.Db, s.Driver)
+}
+
+// Close drops the shadow database.
+func (s *ShadowDb) Close() error {
+	if s.schemaName != "" {
+		if _, err := s.Db.Exec("DROP SCHEMA " + s.schemaName + " CASCADE"); err != nil {
+			s.Db.Close()
+			return fmt.Errorf("failed to drop shadow schema '%s': %v", s.schemaName, err)
+		}
+	}
+
+	return s.Db.Close()
+}
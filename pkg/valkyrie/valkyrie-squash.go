@@ -0,0 +1,275 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+const (
+	// SquashFromSql builds the baseline by concatenating the squashed files.
+	SquashFromSql = "sql"
+	// SquashFromDump builds the baseline from the schema the squashed files create.
+	SquashFromDump = "dump"
+)
+
+var ErrPartiallyApplied = errors.New("only some of the squashed migrations are applied")
+
+type SquashOptions struct {
+	// Before is the yyyymmdd date the squashed migrations are dated before.
+	Before string
+	From   string
+}
+
+// squashTarget is a database whose tracking rows are updated by Squash.
+type squashTarget struct {
+	connString string
+	repo       models.MigrationStorer
+}
+
+// Squash replaces the migrations of a group dated before opts.Before with a
+// single baseline migration. Databases that applied every squashed migration
+// log the baseline as applied instead, databases that applied none of them
+// run the baseline on their next migrate.
+func Squash(migrationFolder string, groupName string, connStrings []string, opts SquashOptions) error {
+	if err := migrations.CheckDate(opts.Before); err != nil {
+		return err
+	}
+
+	if opts.From != SquashFromSql && opts.From != SquashFromDump {
+		return fmt.Errorf("invalid baseline source '%s', expected one of: %s, %s", opts.From, SquashFromSql, SquashFromDump)
+	}
+
+	group, err := readGroup(migrationFolder, groupName)
+	if err != nil {
+		return err
+	}
+
+	squashed := make([]string, 0)
+	downScripts := make([]string, 0)
+	for _, mig := range group.Migrations {
+		if migrations.MigrationDate(mig.Name) < opts.Before {
+			squashed = append(squashed, mig.Name)
+			if mig.DownName != "" {
+				downScripts = append(downScripts, mig.DownName)
+			}
+		}
+	}
+
+	if len(squashed) < 2 {
+		return fmt.Errorf("group '%s' has %v migrations dated before %s, nothing to squash", groupName, len(squashed), opts.Before)
+	}
+
+	baseline := migrations.MigrationDate(squashed[len(squashed)-1]) + "_baseline.sql"
+	for _, mig := range group.Migrations[len(squashed):] {
+		if mig.Name == baseline {
+			return fmt.Errorf("migration '%s/%s' already exists", groupName, baseline)
+		}
+	}
+
+	fmt.Printf("squashing %v migrations of group %s into %s\n", len(squashed), groupName, baseline)
+
+	// check every database before changing anything
+	targets := make([]squashTarget, 0)
+	driver := ""
+	for i, connString := range connStrings {
+		db, dbDriver, err := OpenDb(connString)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		// the dump baseline is built for the driver of the first database
+		if i == 0 {
+			driver = dbDriver
+		} else if dbDriver != driver {
+			return fmt.Errorf("can't squash %s and %s databases together, %s is %s", driver, dbDriver, connString, dbDriver)
+		}
+
+		repo := NewMigrationStorerForDb(db, dbDriver)
+		applied, err := countApplied(repo, groupName, squashed)
+		if err != nil {
+			return fmt.Errorf("%s: %v", connString, err)
+		}
+
+		switch applied {
+		case 0:
+			fmt.Printf("* %s: no squashed migrations applied, it will run the baseline\n", connString)
+		case len(squashed):
+			targets = append(targets, squashTarget{connString: connString, repo: repo})
+		default:
+			return fmt.Errorf("%w: %s applied %v of %v, migrate it before squashing", ErrPartiallyApplied, connString, applied, len(squashed))
+		}
+	}
+
+	groupPath := path.Join(migrationFolder, groupName)
+	baselinePath := path.Join(groupPath, baseline)
+
+	var content string
+	if opts.From == SquashFromDump {
+		content, err = baselineFromDump(groupPath, squashed, connStrings[0], driver)
+	} else {
+		content, err = baselineFromSql(groupPath, squashed)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the tracking rows are rewritten before the files change, so a failure
+	// leaves the migration folder as it was
+	reason := fmt.Sprintf("squashed into %s", baseline)
+	for i, target := range targets {
+		if err := target.repo.Squash(groupName, squashed, baseline, reason); err != nil {
+			err = fmt.Errorf("failed to update the tracking rows of %s, the squashed files were kept: %v", target.connString, err)
+			return errors.Join(err, undoSquash(targets[:i], groupName, squashed, baseline))
+		}
+		fmt.Printf("* %s: logged %s as applied\n", target.connString, baseline)
+	}
+
+	header := fmt.Sprintf("-- baseline of group %s, squashes %v migrations dated before %s\n", groupName, len(squashed), opts.Before)
+	if err := os.WriteFile(baselinePath, []byte(header+content), 0644); err != nil {
+		err = fmt.Errorf("failed to write %s, the squashed files were kept: %v", baselinePath, err)
+		if removeErr := os.Remove(baselinePath); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = errors.Join(err, removeErr)
+		}
+		return errors.Join(err, undoSquash(targets, groupName, squashed, baseline))
+	}
+
+	// the baseline can't be reverted, so the down scripts go too
+	for _, name := range append(squashed, downScripts...) {
+		if name == baseline {
+			continue
+		}
+		if err := os.Remove(path.Join(groupPath, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s replaces the squashed migrations and is logged as applied, but %s couldn't be removed, remove the squashed files left by hand: %v", baseline, name, err)
+		}
+	}
+
+	fmt.Printf("replaced %v migrations with %s\n", len(squashed), baseline)
+	return nil
+}
+
+// undoSquash logs the squashed migrations of the targets as applied again in
+// place of the baseline, after a squash failed. The error names the
+// databases that couldn't be restored.
+func undoSquash(targets []squashTarget, groupName string, squashed []string, baseline string) error {
+	reason := fmt.Sprintf("restored, squashing into %s failed", baseline)
+
+	marks := make([]models.AppliedMark, len(squashed))
+	for i, name := range squashed {
+		marks[i] = models.AppliedMark{GroupName: groupName, MigrationName: name, Reason: reason}
+	}
+
+	failed := make([]string, 0)
+	for _, target := range targets {
+		if _, err := target.repo.MarkAllApplied(marks); err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", target.connString, err))
+			continue
+		}
+
+		if err := target.repo.MarkPending(groupName, baseline, reason); err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", target.connString, err))
+			continue
+		}
+
+		fmt.Printf("* %s: restored the squashed migrations\n", target.connString)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("these databases still log %s in place of the squashed migrations, mark them back by hand: %s", baseline, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func readGroup(migrationFolder string, groupName string) (*models.MigrationGroup, error) {
+	groups, err := readMigrationGroups(migrationFolder, migrations.GroupFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if group.Name == groupName {
+			return group, nil
+		}
+	}
+
+	return nil, fmt.Errorf("group '%s' not found in %s", groupName, migrationFolder)
+}
+
+// countApplied returns how many of the given migrations the database applied.
+func countApplied(repo models.MigrationStorer, groupName string, names []string) (int, error) {
+	if err := repo.EnsureCreated(); err != nil {
+		return 0, err
+	}
+
+	applied, err := repo.GetMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, group := range applied {
+		if group.Name != groupName {
+			continue
+		}
+		for _, mig := range group.Migrations {
+			for _, name := range names {
+				if mig.Name == name {
+					count++
+				}
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func baselineFromSql(groupPath string, names []string) (string, error) {
+	var sb strings.Builder
+	for _, name := range names {
+		buf, err := os.ReadFile(path.Join(groupPath, name))
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&sb, "\n-- %s\n%s\n", name, strings.TrimSpace(string(buf)))
+	}
+
+	return sb.String(), nil
+}
+
+// baselineFromDump applies the squashed files to a shadow database and
+// returns the ddl creating the resulting schema. Data changes are lost.
+func baselineFromDump(groupPath string, names []string, connString string, driver string) (string, error) {
+	shadow, err := OpenShadowDb(connString, driver)
+	if err != nil {
+		return "", err
+	}
+	defer shadow.Close()
+
+	for _, name := range names {
+		buf, err := os.ReadFile(path.Join(groupPath, name))
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := shadow.Db.Exec(string(buf)); err != nil {
+			return "", fmt.Errorf("failed to apply %s to the shadow database, try --from %s: %v", name, SquashFromSql, err)
+		}
+	}
+
+	s, err := shadow.Schema()
+	if err != nil {
+		return "", err
+	}
+
+	stmts := schema.MigrationSql(&schema.Schema{Driver: driver}, s, driver)
+	return "\n" + strings.Join(stmts, ";\n\n") + ";\n", nil
+}
@@ -0,0 +1,145 @@
+package valkyrie_test
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestSquash(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	writeMigration(t, migrationFolder, "Users", "20240102_add_name.sql", "ALTER TABLE users ADD COLUMN name TEXT;")
+	writeMigration(t, migrationFolder, "Users", "20240201_ix_users.sql", "CREATE INDEX ix_users_name ON users (name);")
+
+	applied := path.Join(dir, "applied.db")
+	partial := path.Join(dir, "partial.db")
+	fresh := path.Join(dir, "fresh.db")
+
+	migrate := func(connString string, opts ...valkyrie.MigrateOption) {
+		repo, err := valkyrie.NewMigrationStorer(connString)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := valkyrie.NewMigrateApp(repo, opts...).Run(migrationFolder); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrate(applied)
+	migrate(partial, valkyrie.WithTarget(&valkyrie.MigrationTarget{Steps: 1}))
+
+	opts := valkyrie.SquashOptions{Before: "20240110", From: valkyrie.SquashFromSql}
+
+	err := valkyrie.Squash(migrationFolder, "Users", []string{applied, partial}, opts)
+	if !errors.Is(err, valkyrie.ErrPartiallyApplied) {
+		t.Fatalf("expected '%v', got '%v'", valkyrie.ErrPartiallyApplied, err)
+	}
+
+	if err := valkyrie.Squash(migrationFolder, "Users", []string{applied, fresh}, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(path.Join(migrationFolder, "Users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedFiles := []string{"20240102_baseline.sql", "20240201_ix_users.sql"}
+	if len(entries) != len(expectedFiles) {
+		t.Fatalf("expected '%v' files, got '%v'", len(expectedFiles), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Name() != expectedFiles[i] {
+			t.Errorf("expected '%v', got '%v'", expectedFiles[i], entry.Name())
+		}
+	}
+
+	for _, connString := range []string{applied, fresh} {
+		repo, err := valkyrie.NewMigrationStorer(connString)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plan, err := valkyrie.NewMigrateApp(repo).Plan(migrationFolder)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedPending := 0
+		if connString == fresh {
+			expectedPending = 1
+		}
+		if len(plan.Pending) != expectedPending {
+			t.Errorf("%s: expected '%v' pending groups, got '%v'", path.Base(connString), expectedPending, len(plan.Pending))
+		}
+	}
+
+	migrate(fresh)
+}
+
+func TestSquashRestoresTrackingRowsOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	writeMigration(t, migrationFolder, "Users", "20240102_add_name.sql", "ALTER TABLE users ADD COLUMN name TEXT;")
+
+	first := path.Join(dir, "first.db")
+	second := path.Join(dir, "second.db")
+
+	for _, connString := range []string{first, second} {
+		repo, err := valkyrie.NewMigrationStorer(connString)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the second database can't be written, so its tracking rows fail to update
+	readOnly := "file:" + second + "?mode=ro"
+	opts := valkyrie.SquashOptions{Before: "20240110", From: valkyrie.SquashFromSql}
+	if err := valkyrie.Squash(migrationFolder, "Users", []string{first, readOnly}, opts); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries, err := os.ReadDir(path.Join(migrationFolder, "Users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedFiles := []string{"20240101_cr_users.sql", "20240102_add_name.sql"}
+	if len(entries) != len(expectedFiles) {
+		t.Fatalf("expected '%v' files, got '%v'", len(expectedFiles), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Name() != expectedFiles[i] {
+			t.Errorf("expected '%v', got '%v'", expectedFiles[i], entry.Name())
+		}
+	}
+
+	for _, connString := range []string{first, second} {
+		repo, err := valkyrie.NewMigrationStorer(connString)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plan, err := valkyrie.NewMigrateApp(repo).Plan(migrationFolder)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(plan.Pending) != 0 {
+			t.Errorf("%s: expected '0' pending groups, got '%v'", path.Base(connString), len(plan.Pending))
+		}
+	}
+}
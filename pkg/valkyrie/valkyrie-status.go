@@ -0,0 +1,55 @@
+package valkyrie
+
+import (
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+)
+
+// Status prints how many migrations of each group were applied and which ones
+// are pending, without executing anything. It doesn't write to the database,
+// missing tracking tables are taken as nothing applied.
+func (app MigrateApp) Status(migrationFolder string) error {
+	fsys, err := migrationFS(migrationFolder)
+	if err != nil {
+		return err
+	}
+
+	plan, err := app.planFS(fsys, true)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range plan.Groups {
+		appliedCount := 0
+		if applied := helpers.FindMigrationGroup(plan.Applied, group.Name); applied != nil {
+			appliedCount = len(applied.Migrations)
+		}
+
+		pendingCount := 0
+		pending := findPlanGroup(plan, group.Name)
+		if pending != nil {
+			pendingCount = len(pending.Migrations)
+		}
+
+		fmt.Printf("* %s: %v applied, %v pending\n", group.Name, appliedCount, pendingCount)
+
+		if pending == nil {
+			continue
+		}
+
+		for _, mig := range pending.Migrations {
+			if o := plan.FindOutOfOrder(group.Name, mig.Name); o != nil {
+				fmt.Printf("\t   %s [out of order: dated before %s]\n", mig.Name, o.LatestApplied)
+			} else {
+				fmt.Printf("\t   %s\n", mig.Name)
+			}
+		}
+	}
+
+	if len(plan.OutOfOrder) > 0 {
+		fmt.Printf("found %v out-of-order migration(s), the current policy is '%s'\n", len(plan.OutOfOrder), app.outOfOrderPolicy)
+	}
+
+	return nil
+}
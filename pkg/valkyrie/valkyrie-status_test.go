@@ -0,0 +1,64 @@
+package valkyrie_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func TestInspectionIsReadOnly(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		opts    []valkyrie.MigrateOption
+		inspect func(app *valkyrie.MigrateApp, migrationFolder string) error
+	}{
+		{
+			desc: "status",
+			inspect: func(app *valkyrie.MigrateApp, migrationFolder string) error {
+				return app.Status(migrationFolder)
+			},
+		},
+		{
+			desc: "dry run",
+			opts: []valkyrie.MigrateOption{valkyrie.WithDryRun(true)},
+			inspect: func(app *valkyrie.MigrateApp, migrationFolder string) error {
+				return app.Run(migrationFolder)
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+			connString := path.Join(dir, "fresh.db")
+
+			writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tC.inspect(valkyrie.NewMigrateApp(repo, tC.opts...), migrationFolder); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := helpers.GetDb(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			var tables int
+			if err := db.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&tables); err != nil {
+				t.Fatal(err)
+			}
+			if tables != 0 {
+				t.Errorf("expected '%v', got '%v'", 0, tables)
+			}
+		})
+	}
+}
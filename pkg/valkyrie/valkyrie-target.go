@@ -0,0 +1,113 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+)
+
+var ErrTargetNotPending = errors.New("the target migration is not pending")
+
+// MigrationTarget limits a run to part of the pending plan. Only one of its
+// fields is expected to be set.
+type MigrationTarget struct {
+	// GroupName and MigrationName stop the run after the referenced migration.
+	GroupName     string
+	MigrationName string
+	// Date only runs migrations dated on or before it (yyyymmdd).
+	Date string
+	// Steps only runs the first n pending migrations.
+	Steps int
+}
+
+// NewMigrationTarget validates the target flags. It returns nil when none of
+// them is set, meaning the run goes up to the latest migration.
+func NewMigrationTarget(migrationRef, date string, steps int) (*MigrationTarget, error) {
+	target := &MigrationTarget{}
+
+	switch {
+	case migrationRef != "":
+		groupName, migrationName, err := migrations.ParseMigrationRef(migrationRef)
+		if err != nil {
+			return nil, err
+		}
+		target.GroupName, target.MigrationName = groupName, migrationName
+	case date != "":
+		if err := migrations.CheckDate(date); err != nil {
+			return nil, fmt.Errorf("(%s): target date doesn't match expected format (yyyymmdd)", date)
+		}
+		target.Date = date
+	case steps < 0:
+		return nil, fmt.Errorf("steps must be a positive number, got %v", steps)
+	case steps > 0:
+		target.Steps = steps
+	default:
+		return nil, nil
+	}
+
+	return target, nil
+}
+
+func (target MigrationTarget) String() string {
+	switch {
+	case target.MigrationName != "":
+		return target.GroupName + "/" + target.MigrationName
+	case target.Date != "":
+		return "date " + target.Date
+	default:
+		return fmt.Sprintf("%v step(s)", target.Steps)
+	}
+}
+
+// apply trims the ordered pending groups so the run stops at the target.
+func (target MigrationTarget) apply(pending []*models.MigrationGroup) ([]*models.MigrationGroup, error) {
+	trimmed := make([]*models.MigrationGroup, 0, len(pending))
+	steps := 0
+	reached := false
+
+	for _, group := range pending {
+		if reached {
+			break
+		}
+
+		migs := make([]models.Migration, 0, len(group.Migrations))
+
+		for _, mig := range group.Migrations {
+			if target.Date != "" {
+				if migrations.MigrationDate(mig.Name) > target.Date {
+					continue
+				}
+			} else if reached {
+				break
+			}
+
+			migs = append(migs, mig)
+			steps++
+
+			if target.Steps > 0 && steps == target.Steps {
+				reached = true
+			} else if group.Name == target.GroupName && mig.Name == target.MigrationName {
+				reached = true
+			}
+		}
+
+		if len(migs) == 0 {
+			continue
+		}
+
+		trimmed = append(trimmed, &models.MigrationGroup{
+			Id:             group.Id,
+			Name:           group.Name,
+			Migrations:     migs,
+			MigrationCount: len(migs),
+		})
+	}
+
+	if target.MigrationName != "" && !reached {
+		return nil, fmt.Errorf("%w: %s", ErrTargetNotPending, target)
+	}
+
+	return trimmed, nil
+}
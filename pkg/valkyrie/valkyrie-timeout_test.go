@@ -0,0 +1,139 @@
+package valkyrie_test
+
+import (
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/internal/models"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+// slowMigration counts long enough to outlast the timeouts of the test.
+const slowMigration = "CREATE TABLE numbers AS WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 100000000) SELECT x FROM c;"
+
+func TestRunTimeouts(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		header      string
+		opts        []valkyrie.MigrateOption
+		expectedErr bool
+	}{
+		{
+			desc:        "statement timeout",
+			opts:        []valkyrie.MigrateOption{valkyrie.WithTimeouts(models.Timeouts{Statement: 50 * time.Millisecond})},
+			expectedErr: true,
+		},
+		{
+			desc:        "header statement timeout",
+			header:      "-- valkyrie:statement-timeout 50ms\n",
+			expectedErr: true,
+		},
+		{
+			desc:        "run timeout",
+			opts:        []valkyrie.MigrateOption{valkyrie.WithRunTimeout(50 * time.Millisecond)},
+			expectedErr: true,
+		},
+		{
+			desc:   "header without limit",
+			header: "-- valkyrie:statement-timeout 0\n",
+			opts:   []valkyrie.MigrateOption{valkyrie.WithRunTimeout(time.Minute)},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			migrationFolder := path.Join(dir, "migrations")
+			connString := path.Join(dir, "timeout.db")
+
+			writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+			if tC.expectedErr {
+				writeMigration(t, migrationFolder, "Users", "20240102_numbers.sql", tC.header+slowMigration)
+			} else {
+				writeMigration(t, migrationFolder, "Users", "20240102_numbers.sql", tC.header+"CREATE TABLE numbers (x INTEGER);")
+			}
+
+			repo, err := valkyrie.NewMigrationStorer(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = valkyrie.NewMigrateApp(repo, tC.opts...).Run(migrationFolder)
+			if (err != nil) != tC.expectedErr {
+				t.Fatalf("expected error: '%v', got '%v'", tC.expectedErr, err)
+			}
+
+			db, err := helpers.GetDb(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			// a timed out run rolls back every migration
+			expected := 1
+			if tC.expectedErr {
+				expected = 0
+			}
+
+			var tables int
+			if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'").Scan(&tables); err != nil {
+				t.Fatal(err)
+			}
+			if tables != expected {
+				t.Errorf("expected '%v', got '%v'", expected, tables)
+			}
+		})
+	}
+}
+
+func TestRunKeepsBusyTimeout(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+
+	// each file logs the busy timeout it ran with
+	logTimeout := "INSERT INTO busy_log (file, timeout) SELECT '%s', timeout FROM pragma_busy_timeout;"
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_log.sql", "CREATE TABLE busy_log (file TEXT, timeout INTEGER);")
+	writeMigration(t, migrationFolder, "Users", "20240102_default.sql", fmt.Sprintf(logTimeout, "default"))
+	writeMigration(t, migrationFolder, "Users", "20240103_header.sql", "-- valkyrie:lock-timeout 2s\n"+fmt.Sprintf(logTimeout, "header"))
+	writeMigration(t, migrationFolder, "Users", "20240104_after.sql", fmt.Sprintf(logTimeout, "after"))
+
+	db, err := helpers.GetDb(path.Join(dir, "busy.db") + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// the run and the checks share the connection
+	db.SetMaxOpenConns(1)
+
+	repo := valkyrie.NewMigrationStorerForDb(db, valkyrie.DriverSqlite)
+	if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		query    string
+		expected int
+	}{
+		{desc: "file without header", query: "SELECT timeout FROM busy_log WHERE file = 'default'", expected: 5000},
+		{desc: "file with lock timeout", query: "SELECT timeout FROM busy_log WHERE file = 'header'", expected: 2000},
+		{desc: "file after lock timeout", query: "SELECT timeout FROM busy_log WHERE file = 'after'", expected: 5000},
+		{desc: "connection after run", query: "PRAGMA busy_timeout", expected: 5000},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var timeout int
+			if err := db.QueryRow(tC.query).Scan(&timeout); err != nil {
+				t.Fatal(err)
+			}
+
+			if timeout != tC.expected {
+				t.Errorf("expected '%v', got '%v'", tC.expected, timeout)
+			}
+		})
+	}
+}
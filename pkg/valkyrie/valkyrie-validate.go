@@ -0,0 +1,30 @@
+package valkyrie
+
+import (
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/migrations"
+)
+
+// Validate checks the layout and file names of the migration folder without
+// connecting to a database.
+func Validate(migrationFolder string, include []string, exclude []string) error {
+	filter := migrations.GroupFilter{
+		Include: include,
+		Exclude: exclude,
+	}
+
+	migrationGroups, err := readMigrationGroups(migrationFolder, filter)
+	if err != nil {
+		return err
+	}
+
+	migrationCount := 0
+	for _, group := range migrationGroups {
+		fmt.Printf("* %s: %v migrations\n", group.Name, len(group.Migrations))
+		migrationCount += len(group.Migrations)
+	}
+
+	fmt.Printf("%v groups and %v migrations are valid\n", len(migrationGroups), migrationCount)
+	return nil
+}
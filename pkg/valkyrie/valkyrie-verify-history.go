@@ -0,0 +1,46 @@
+package valkyrie
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/marianop9/valkyrie-migrate/internal/schema"
+)
+
+var ErrHistoryDiverged = errors.New("a fresh install doesn't match the target database")
+
+// VerifyHistory applies the whole migration folder to a shadow database and
+// compares the resulting schema with the target database referenced by connString.
+// Group filters in opts are ignored, the tables of the groups left out would
+// be reported as differences.
+func VerifyHistory(migrationFolder string, connString string, opts ...MigrateOption) error {
+	db, driver, err := OpenDb(connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	target, err := IntrospectSchema(db, driver)
+	if err != nil {
+		return err
+	}
+
+	shadow, err := OpenShadowDb(connString, driver)
+	if err != nil {
+		return err
+	}
+	defer shadow.Close()
+
+	fmt.Println("applying migration history to a shadow database")
+	allGroups := append(append([]MigrateOption{}, opts...), WithGroups(nil, nil))
+	if err := shadow.Migrate(migrationFolder, allGroups...); err != nil {
+		return errors.Join(errors.New("failed to apply the migration history"), err)
+	}
+
+	fresh, err := shadow.Schema()
+	if err != nil {
+		return err
+	}
+
+	return reportDifferences(schema.Compare(fresh, target), "fresh install", ErrHistoryDiverged)
+}
@@ -0,0 +1,83 @@
+package valkyrie_test
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+func writeMigration(t *testing.T, migrationFolder string, group string, name string, sql string) {
+	t.Helper()
+
+	groupPath := path.Join(migrationFolder, group)
+	if err := os.MkdirAll(groupPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path.Join(groupPath, name), []byte(sql), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyHistory(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	connString := path.Join(dir, "target.db")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);")
+	writeMigration(t, migrationFolder, "Users", "20240102_ix_users.sql", "CREATE INDEX ix_users_name ON users (name);")
+
+	repo, err := valkyrie.NewMigrationStorer(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := valkyrie.VerifyHistory(migrationFolder, connString); err != nil {
+		t.Errorf("expected '%v', got '%v'", nil, err)
+	}
+
+	db, err := helpers.GetDb(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("ALTER TABLE users ADD COLUMN email TEXT"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := valkyrie.VerifyHistory(migrationFolder, connString); !errors.Is(err, valkyrie.ErrHistoryDiverged) {
+		t.Errorf("expected '%v', got '%v'", valkyrie.ErrHistoryDiverged, err)
+	}
+}
+
+func TestVerifyHistoryIgnoresGroupFilters(t *testing.T) {
+	dir := t.TempDir()
+	migrationFolder := path.Join(dir, "migrations")
+	connString := path.Join(dir, "target.db")
+
+	writeMigration(t, migrationFolder, "Users", "20240101_cr_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	writeMigration(t, migrationFolder, "Orders", "20240102_cr_orders.sql", "CREATE TABLE orders (id INTEGER PRIMARY KEY);")
+
+	repo, err := valkyrie.NewMigrationStorer(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := valkyrie.NewMigrateApp(repo).Run(migrationFolder); err != nil {
+		t.Fatal(err)
+	}
+
+	// the orders table would be reported as extra if only Users was replayed
+	if err := valkyrie.VerifyHistory(migrationFolder, connString, valkyrie.WithGroups([]string{"Users"}, nil)); err != nil {
+		t.Errorf("expected '%v', got '%v'", nil, err)
+	}
+}
@@ -0,0 +1,16 @@
+package valkyrietest
+
+import "io/fs"
+
+// WithDatabase exposes withDatabase to the external tests.
+var WithDatabase = withDatabase
+
+// TemplateName returns the name of the template database of fsys.
+func TemplateName(fsys fs.FS) (string, error) {
+	hash, err := hashMigrations(fsys)
+	if err != nil {
+		return "", err
+	}
+
+	return templateName(hash), nil
+}
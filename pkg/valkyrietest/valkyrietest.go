@@ -0,0 +1,342 @@
+// Package valkyrietest provides migrated databases for application test suites.
+//
+// Migrations are read from an fs.FS laid out like a migration folder, usually
+// an embed.FS. The migrated SQLite file and Postgres template database are
+// built once per migration content and copied for every test, so large suites
+// don't migrate from scratch in every test.
+package valkyrietest
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marianop9/valkyrie-migrate/internal/helpers"
+	"github.com/marianop9/valkyrie-migrate/pkg/valkyrie"
+)
+
+var (
+	mu sync.Mutex
+	// sqliteTemplates holds the migrated sqlite database file of every migration hash.
+	sqliteTemplates = make(map[string][]byte)
+	// postgresTemplates holds the migrated template database of every server
+	// and migration hash.
+	postgresTemplates = make(map[string]string)
+)
+
+// SQLite returns a sqlite database, in a temporary file removed after the
+// test, with every migration of fsys applied.
+func SQLite(t testing.TB, fsys fs.FS) *sql.DB {
+	t.Helper()
+
+	template, err := sqliteTemplate(fsys)
+	if err != nil {
+		t.Fatalf("valkyrietest: failed to migrate sqlite template: %v", err)
+	}
+
+	dbPath := path.Join(t.TempDir(), "valkyrietest.db")
+	if err := os.WriteFile(dbPath, template, 0600); err != nil {
+		t.Fatalf("valkyrietest: %v", err)
+	}
+
+	db, err := helpers.GetDb(dbPath)
+	if err != nil {
+		t.Fatalf("valkyrietest: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// PostgresDatabase creates a database on the Postgres server referenced by
+// dbUrl with every migration of fsys applied and returns it. It's dropped in
+// t.Cleanup.
+//
+// The database is cloned with CREATE DATABASE ... TEMPLATE, so the user of
+// dbUrl needs the CREATEDB privilege. The template, named after the content
+// of the migrations, is migrated the first time it's needed and kept on the
+// server for later runs. Templates of migrations that changed are left
+// behind, PrunePostgresDatabases drops them.
+func PostgresDatabase(t testing.TB, dbUrl string, fsys fs.FS) *sql.DB {
+	t.Helper()
+
+	template, err := postgresTemplate(dbUrl, fsys)
+	if err != nil {
+		t.Fatalf("valkyrietest: failed to migrate postgres template: %v", err)
+	}
+
+	admin, err := helpers.GetPostgresDb(dbUrl)
+	if err != nil {
+		t.Fatalf("valkyrietest: %v", err)
+	}
+	defer admin.Close()
+
+	dbName := fmt.Sprintf("valkyrietest_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + dbName + " TEMPLATE " + template); err != nil {
+		t.Fatalf("valkyrietest: failed to create database: %v", err)
+	}
+
+	db, err := helpers.GetPostgresDb(withDatabase(dbUrl, dbName))
+	if err != nil {
+		t.Fatalf("valkyrietest: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+
+		admin, err := helpers.GetPostgresDb(dbUrl)
+		if err != nil {
+			t.Errorf("valkyrietest: %v", err)
+			return
+		}
+		defer admin.Close()
+
+		if _, err := admin.Exec("DROP DATABASE " + dbName); err != nil {
+			t.Errorf("valkyrietest: failed to drop database '%s': %v", dbName, err)
+		}
+	})
+
+	return db
+}
+
+func sqliteTemplate(fsys fs.FS) ([]byte, error) {
+	hash, err := hashMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if template, ok := sqliteTemplates[hash]; ok {
+		return template, nil
+	}
+
+	dir, err := os.MkdirTemp("", "valkyrietest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := path.Join(dir, "template.db")
+	db, err := helpers.GetDb(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	app := valkyrie.NewMigrateApp(valkyrie.NewMigrationStorerForDb(db, valkyrie.DriverSqlite),
+		valkyrie.WithOutOfOrderPolicy(valkyrie.OutOfOrderAllow),
+	)
+
+	err = app.RunFS(fsys)
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteTemplates[hash] = template
+	return template, nil
+}
+
+// postgresTemplate returns the name of a database on the server of dbUrl with
+// every migration of fsys applied. It's kept between test runs, as its name
+// is derived from the migration content.
+func postgresTemplate(dbUrl string, fsys fs.FS) (string, error) {
+	hash, err := hashMigrations(fsys)
+	if err != nil {
+		return "", err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := dbUrl + "\x00" + hash
+	if template, ok := postgresTemplates[key]; ok {
+		return template, nil
+	}
+
+	admin, err := helpers.GetPostgresDb(dbUrl)
+	if err != nil {
+		return "", err
+	}
+	defer admin.Close()
+
+	template := templateName(hash)
+	exists, err := databaseExists(admin, template)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		// the template is migrated under a temporary name and renamed once
+		// complete, so a concurrent or failed run never leaves a partial one
+		building := fmt.Sprintf("%s_%d", template, time.Now().UnixNano())
+		if err := migrateTemplate(admin, dbUrl, building, fsys); err != nil {
+			return "", err
+		}
+
+		if _, err := admin.Exec("ALTER DATABASE " + building + " RENAME TO " + template); err != nil {
+			// another run may have renamed its template first
+			if _, dropErr := admin.Exec("DROP DATABASE " + building); dropErr != nil {
+				return "", dropErr
+			}
+			if exists, existsErr := databaseExists(admin, template); existsErr != nil || !exists {
+				return "", err
+			}
+		}
+	}
+
+	postgresTemplates[key] = template
+	return template, nil
+}
+
+// PrunePostgresDatabases drops the databases valkyrietest left on the server
+// of dbUrl: the templates of migrations other than the ones of keep and the
+// test databases of runs that couldn't clean up. Databases in use are
+// skipped. It's meant for TestMain, after the tests ran, or a cleanup job.
+func PrunePostgresDatabases(dbUrl string, keep ...fs.FS) error {
+	kept := make(map[string]bool, len(keep))
+	for _, fsys := range keep {
+		hash, err := hashMigrations(fsys)
+		if err != nil {
+			return err
+		}
+		kept[templateName(hash)] = true
+	}
+
+	admin, err := helpers.GetPostgresDb(dbUrl)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	rows, err := admin.Query(`SELECT d.datname FROM pg_database d
+		WHERE d.datname LIKE 'valkyrietest\_%'
+			AND NOT EXISTS (SELECT 1 FROM pg_stat_activity a WHERE a.datname = d.datname)`)
+	if err != nil {
+		return err
+	}
+
+	unused := make([]string, 0)
+	for rows.Next() {
+		var dbName string
+		if err := rows.Scan(&dbName); err != nil {
+			rows.Close()
+			return err
+		}
+		if !kept[dbName] {
+			unused = append(unused, dbName)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var errs error
+	for _, dbName := range unused {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + dbName); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to drop database '%s': %w", dbName, err))
+			continue
+		}
+
+		for key, template := range postgresTemplates {
+			if template == dbName {
+				delete(postgresTemplates, key)
+			}
+		}
+	}
+
+	return errs
+}
+
+// templateName is the name of the template database of a migration hash.
+func templateName(hash string) string {
+	return "valkyrietest_tpl_" + hash[:16]
+}
+
+func migrateTemplate(admin *sql.DB, dbUrl string, dbName string, fsys fs.FS) error {
+	if _, err := admin.Exec("CREATE DATABASE " + dbName); err != nil {
+		return err
+	}
+
+	db, err := helpers.GetPostgresDb(withDatabase(dbUrl, dbName))
+	if err != nil {
+		return err
+	}
+
+	app := valkyrie.NewMigrateApp(valkyrie.NewMigrationStorerForDb(db, valkyrie.DriverPostgres),
+		valkyrie.WithOutOfOrderPolicy(valkyrie.OutOfOrderAllow),
+	)
+
+	// the template can't be cloned while connections to it are open
+	err = app.RunFS(fsys)
+	db.Close()
+	if err != nil {
+		_, dropErr := admin.Exec("DROP DATABASE " + dbName)
+		return errors.Join(err, dropErr)
+	}
+
+	return nil
+}
+
+func databaseExists(db *sql.DB, dbName string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", dbName).Scan(&exists)
+	return exists, err
+}
+
+// hashMigrations identifies the content of the migrations, fs.FS values
+// can't be used as map keys.
+func hashMigrations(fsys fs.FS) (string, error) {
+	h := sha256.New()
+
+	err := fs.WalkDir(fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		buf, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s\x00%d\x00", filePath, len(buf))
+		h.Write(buf)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// withDatabase points dbUrl to another database of the same server.
+func withDatabase(dbUrl string, dbName string) string {
+	if u, err := url.Parse(dbUrl); err == nil && strings.Contains(dbUrl, "://") {
+		u.Path = "/" + dbName
+		return u.String()
+	}
+
+	// key=value connection string, the last dbname wins
+	return dbUrl + " dbname=" + dbName
+}